@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/gforien/go/pkg/fswatch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	devWatch       watchFlags
+	devListenFlag  string
+	devSignalFlag  string
+	devGraceFlag   time.Duration
+	devBuildFlags  []string
+	devDefaultGlob = []string{"*.go", "go.mod", "go.sum", "go.work"}
+)
+
+var devCmd = &cobra.Command{
+	Use:   "dev [flags] [package] [-- args...]",
+	Short: "Rebuild and restart a Go program when its sources change",
+	Long: `Rebuild and restart a Go program when its sources change, like a 'go run' loop.
+
+The package (default ".") is built with 'go build' into a temporary binary,
+which replaces the running program only if the build succeeds.
+When the build fails, the compiler errors are printed and the previous program keeps running.
+
+Go files, go.mod, go.sum and go.work are watched. Add embedded assets with --include.
+
+With --listen, fswatch holds the listening socket and passes it to the program
+as file descriptor 3, with LISTEN_FDS=1 as with systemd socket activation.
+The new program is started before the previous one is stopped, and connections
+are queued by the kernel meanwhile: clients never see a connection refused.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pkg := "."
+		n := cmd.ArgsLenAtDash()
+		if n > 1 || (n < 0 && len(args) > 1) {
+			return fmt.Errorf("expects a single package, got %v", args)
+		}
+		if n == 1 || (n < 0 && len(args) == 1) {
+			pkg, args = args[0], args[1:]
+		}
+
+		sig, err := parseSignal(devSignalFlag)
+		if err != nil {
+			return err
+		}
+
+		dir, err := os.MkdirTemp("", "fswatch-dev-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		d := &devServer{pkg: pkg, args: args, dir: dir, signal: sig, grace: devGraceFlag, buildFlags: devBuildFlags}
+		if devListenFlag != "" {
+			if d.listener, err = listen(devListenFlag); err != nil {
+				return err
+			}
+			defer d.listener.Close()
+			logf("listening at %s", devListenFlag)
+		}
+		defer d.shutdown()
+
+		devWatch.include = append(devWatch.include, devDefaultGlob...)
+		w, opts, err := devWatch.watcher()
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+
+		ctx := cmd.Context()
+		d.reload(ctx)
+		err = w.WatchBatch(ctx, devWatch.debounce, func(changes []fswatch.Change) {
+			logf("%d changes, rebuilding", len(changes))
+			d.reload(ctx)
+		}, opts...)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	},
+}
+
+// devServer builds a Go package and swaps the running program when the build succeeds
+type devServer struct {
+	pkg        string
+	args       []string
+	buildFlags []string
+	dir        string   // where binaries are built
+	listener   *os.File // passed to the program, if any
+	signal     os.Signal
+	grace      time.Duration
+
+	builds  int
+	current *process
+	bin     string // binary of the current program
+}
+
+// reload builds the package and, if the build succeeds, swaps the running program
+func (d *devServer) reload(ctx context.Context) {
+	d.builds++
+	bin := filepath.Join(d.dir, fmt.Sprintf("build-%d", d.builds))
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+
+	t0 := time.Now()
+	args := append([]string{"build", "-o", bin}, d.buildFlags...)
+	build := exec.CommandContext(ctx, "go", append(args, d.pkg)...)
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		if ctx.Err() == nil {
+			if d.current != nil && !d.current.exited() {
+				logf("build failed (%v), the previous version keeps running", err)
+			} else {
+				logf("build failed (%v)", err)
+			}
+		}
+		return
+	}
+	logf("built %s in %v", d.pkg, time.Since(t0).Round(time.Millisecond))
+
+	var env []string
+	var files []*os.File
+	if d.listener != nil {
+		env = append(env, "LISTEN_FDS=1")
+		files = append(files, d.listener)
+	}
+	p, err := start(append([]string{bin}, d.args...), env, files...)
+	if err != nil {
+		logf("error starting %s: %v", d.pkg, err)
+		_ = os.Remove(bin)
+		return
+	}
+
+	previous, previousBin := d.current, d.bin
+	d.current, d.bin = p, bin
+	go func() {
+		_ = p.wait()
+		logf("%s %s", d.pkg, p.status())
+	}()
+
+	// the previous program is stopped once the new one is running
+	if previous != nil {
+		if killed := previous.stop(d.signal, d.grace); killed {
+			logf("previous version killed after %v", d.grace)
+		}
+		_ = os.Remove(previousBin)
+	}
+}
+
+// shutdown stops the running program, if any
+func (d *devServer) shutdown() {
+	if d.current != nil {
+		d.current.stop(d.signal, d.grace)
+	}
+}
+
+// listen opens a TCP listening socket and returns it as a file to pass to child processes
+func listen(addr string) (*os.File, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	tcp, ok := l.(*net.TCPListener)
+	if !ok {
+		return nil, fmt.Errorf("unexpected listener %T", l)
+	}
+	// File returns a duplicate which keeps the socket open after l is closed
+	return tcp.File()
+}
+
+func init() {
+	devWatch.register(devCmd)
+	devCmd.Flags().StringVarP(&devListenFlag, "listen", "l", "", "hold a listening socket at this address and pass it to the program (e.g. ':8080')")
+	devCmd.Flags().StringVarP(&devSignalFlag, "signal", "s", "SIGTERM", "signal sent to the process group to stop the program")
+	devCmd.Flags().DurationVar(&devGraceFlag, "grace", 5*time.Second, "time to wait after --signal before killing the program")
+	devCmd.Flags().StringSliceVar(&devBuildFlags, "build-flags", nil, "additional flags for 'go build' (e.g. '-race')")
+
+	root.AddCommand(devCmd)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDevServerReload(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program")
+	}
+
+	src := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("error in test setup: %v", err)
+		}
+	}
+	write("go.mod", "module example.com/app\n\ngo 1.21\n")
+	write("main.go", "package main\n\nimport \"time\"\n\nfunc main() { time.Sleep(time.Minute) }\n")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	if err := os.Chdir(src); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	d := &devServer{pkg: ".", dir: t.TempDir(), signal: os.Kill, grace: time.Second}
+	defer d.shutdown()
+
+	d.reload(context.Background())
+	first := d.current
+	if first == nil || first.exited() {
+		t.Fatalf("expected the program to be running")
+	}
+
+	// a failed build keeps the program running
+	write("main.go", "package main\n\nfunc main() { syntax error }\n")
+	d.reload(context.Background())
+	if d.current != first || first.exited() {
+		t.Errorf("expected the previous program to keep running after a failed build")
+	}
+
+	// a successful build swaps the program
+	write("main.go", "package main\n\nimport \"time\"\n\nfunc main() { time.Sleep(2 * time.Minute) }\n")
+	d.reload(context.Background())
+	if d.current == first || d.current == nil {
+		t.Fatalf("expected a new program to be running")
+	}
+	if !first.exited() {
+		t.Errorf("expected the previous program to be stopped")
+	}
+	if _, err := os.Stat(d.bin); err != nil {
+		t.Errorf("expected the binary of the current program to exist: %v", err)
+	}
+}
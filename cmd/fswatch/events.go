@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gforien/go/pkg/fswatch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	eventsWatch watchFlags
+	formatFlag  string
+	onceFlag    bool
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events [flags]",
+	Short: "Print changes as they happen",
+	Long: `Print changes as they happen, to be piped into other tools.
+
+Formats:
+  json  one JSON object per line, with the fields time, op, path, rel_path,
+        from (for renamed paths), is_dir and size
+  null  the changed paths relative to the working directory, each followed
+        by a null byte like find -print0, to be read by xargs -0
+
+Each batch of changes is written at once. With --once, fswatch exits after the first batch:
+  fswatch events --once --format null | xargs -0 gofmt -l`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		sink, err := newSink(formatFlag, os.Stdout, cwd)
+		if err != nil {
+			return err
+		}
+
+		w, opts, err := eventsWatch.watcher()
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		var writeErr error
+		err = w.WatchBatch(ctx, eventsWatch.debounce, func(changes []fswatch.Change) {
+			if writeErr = sink.Write(changes); writeErr != nil || onceFlag {
+				cancel()
+			}
+		}, opts...)
+		if writeErr != nil {
+			return writeErr
+		}
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	},
+}
+
+// newSink returns the sink for a format
+func newSink(format string, w io.Writer, base string) (fswatch.Sink, error) {
+	switch format {
+	case "json":
+		return fswatch.NewJSONSink(w, base), nil
+	case "null":
+		return fswatch.NewNullSink(w, base), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected json or null", format)
+	}
+}
+
+func init() {
+	eventsWatch.register(eventsCmd)
+	eventsCmd.Flags().StringVarP(&formatFlag, "format", "f", "json", "output format: json or null")
+	eventsCmd.Flags().BoolVar(&onceFlag, "once", false, "exit after the first batch of changes")
+	root.AddCommand(eventsCmd)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gforien/go/pkg/fswatch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execWatch    watchFlags
+	restartFlag  bool
+	signalFlag   string
+	graceFlag    time.Duration
+	clearFlag    bool
+	postponeFlag bool
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec [flags] -- <command> [args...]",
+	Short: "Run a command when files change",
+	Long: `Run a command when files change.
+
+By default, the command runs once at start, then again after each batch of changes,
+once the previous run has exited. With --restart, the running command is stopped
+with --signal, sent to its whole process group, and killed after --grace.
+
+The changed paths are passed in environment variables, separated by '` + string(os.PathListSeparator) + `':
+  FSWATCH_CHANGED_PATHS   all the changed paths
+  FSWATCH_CREATED_PATHS   created paths
+  FSWATCH_MODIFIED_PATHS  modified paths
+  FSWATCH_DELETED_PATHS   deleted paths
+  FSWATCH_RENAMED_PATHS   renamed paths, as old=new`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sig, err := parseSignal(signalFlag)
+		if err != nil {
+			return err
+		}
+
+		w, opts, err := execWatch.watcher()
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+
+		s := &supervisor{args: args, signal: sig, grace: graceFlag}
+		defer s.shutdown()
+
+		ctx := cmd.Context()
+		run := func(changes []fswatch.Change) {
+			if clearFlag {
+				clearScreen()
+			}
+			env := changesEnv(changes)
+			var err error
+			if restartFlag {
+				err = s.restart(env)
+			} else {
+				err = s.rerun(ctx, env)
+			}
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logf("error starting command: %v", err)
+			}
+		}
+
+		if !postponeFlag {
+			run(nil)
+		}
+		err = w.WatchBatch(ctx, execWatch.debounce, run, opts...)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	},
+}
+
+// changesEnv returns the environment variables describing changes
+func changesEnv(changes []fswatch.Change) []string {
+	var all, created, modified, deleted, renamed []string
+	for _, c := range changes {
+		path := relative(c.Path)
+		all = append(all, path)
+		switch c.Kind {
+		case fswatch.Created:
+			created = append(created, path)
+		case fswatch.Modified:
+			modified = append(modified, path)
+		case fswatch.Deleted:
+			deleted = append(deleted, path)
+		case fswatch.Renamed:
+			renamed = append(renamed, relative(c.From)+"="+path)
+		}
+	}
+
+	sep := string(filepath.ListSeparator)
+	return []string{
+		"FSWATCH_CHANGED_PATHS=" + strings.Join(all, sep),
+		"FSWATCH_CREATED_PATHS=" + strings.Join(created, sep),
+		"FSWATCH_MODIFIED_PATHS=" + strings.Join(modified, sep),
+		"FSWATCH_DELETED_PATHS=" + strings.Join(deleted, sep),
+		"FSWATCH_RENAMED_PATHS=" + strings.Join(renamed, sep),
+	}
+}
+
+func clearScreen() {
+	// clear the screen and the scrollback, then move the cursor home
+	_, _ = os.Stdout.WriteString("\033[H\033[2J\033[3J")
+}
+
+func init() {
+	execWatch.register(execCmd)
+	execCmd.Flags().BoolVarP(&restartFlag, "restart", "r", false, "restart the command on changes instead of waiting for it to exit")
+	execCmd.Flags().StringVarP(&signalFlag, "signal", "s", "SIGTERM", "signal sent to the process group to stop the command")
+	execCmd.Flags().DurationVar(&graceFlag, "grace", 5*time.Second, "time to wait after --signal before killing the command")
+	execCmd.Flags().BoolVarP(&clearFlag, "clear", "c", false, "clear the screen before each run")
+	execCmd.Flags().BoolVarP(&postponeFlag, "postpone", "p", false, "wait for a change before the first run")
+}
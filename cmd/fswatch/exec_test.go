@@ -0,0 +1,47 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gforien/go/pkg/fswatch"
+)
+
+func TestChangesEnv(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	changes := []fswatch.Change{
+		{Path: "a.go", Kind: fswatch.Created},
+		{Path: "b.go", Kind: fswatch.Modified},
+		{Path: "c.go", Kind: fswatch.Modified},
+		{Path: "d.go", Kind: fswatch.Deleted},
+		{Path: "f.go", Kind: fswatch.Renamed, From: "e.go"},
+	}
+
+	expected := []string{
+		"FSWATCH_CHANGED_PATHS=a.go" + sep + "b.go" + sep + "c.go" + sep + "d.go" + sep + "f.go",
+		"FSWATCH_CREATED_PATHS=a.go",
+		"FSWATCH_MODIFIED_PATHS=b.go" + sep + "c.go",
+		"FSWATCH_DELETED_PATHS=d.go",
+		"FSWATCH_RENAMED_PATHS=e.go=f.go",
+	}
+	if got := changesEnv(changes); !slices.Equal(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, given := range []string{"SIGTERM", "TERM", "term", "sigterm"} {
+		sig, err := parseSignal(given)
+		if err != nil {
+			t.Errorf("parseSignal(%q): unexpected error: %v", given, err)
+			continue
+		}
+		if sig != signals["TERM"] {
+			t.Errorf("parseSignal(%q): expected %v, got %v", given, signals["TERM"], sig)
+		}
+	}
+	if _, err := parseSignal("SIGFOO"); err == nil {
+		t.Errorf("expected an error for an unknown signal")
+	}
+}
@@ -0,0 +1,150 @@
+// fswatch runs commands when files change, using [fswatch.Watcher].
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gforien/go/pkg/fswatch"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := root.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+var root = &cobra.Command{
+	Use:          "fswatch",
+	Short:        "Watch files and react to changes.",
+	SilenceUsage: true,
+}
+
+// watchFlags are the flags shared by the commands watching files
+type watchFlags struct {
+	paths    []string
+	include  []string
+	exclude  []string
+	noIgnore bool
+	poll     time.Duration
+	debounce time.Duration
+	hash     bool
+	rescan   bool
+	symlinks string
+}
+
+func (f *watchFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&f.paths, "watch", "w", []string{"."}, "paths to watch recursively")
+	cmd.Flags().StringSliceVarP(&f.include, "include", "i", nil, "only react to files matching these globs (e.g. '*.go')")
+	cmd.Flags().StringSliceVarP(&f.exclude, "exclude", "e", nil, "ignore files matching these globs (gitignore syntax)")
+	cmd.Flags().BoolVar(&f.noIgnore, "no-ignore", false, "do not read .gitignore files nor skip VCS and editor files")
+	cmd.Flags().DurationVar(&f.poll, "poll", 0, "poll at this interval instead of using OS events (e.g. for NFS)")
+	cmd.Flags().StringVar(&f.symlinks, "symlinks", "link", "symbolic links: link (report the link only), ignore, or follow")
+	cmd.Flags().BoolVar(&f.hash, "hash", false, "ignore writes which leave the content of files unchanged (e.g. touch)")
+	cmd.Flags().BoolVar(&f.rescan, "rescan", false, "rescan the watched paths when the OS drops events, at the cost of a scan at start")
+	cmd.Flags().DurationVarP(&f.debounce, "debounce", "d", 100*time.Millisecond, "wait for this quiet period before reacting")
+}
+
+// watcher returns a watcher for the watched paths, and the options to filter the events
+func (f *watchFlags) watcher() (*fswatch.Watcher, []func(*fswatch.WatchOpts), error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ig, err := fswatch.NewIgnore(cwd, f.exclude...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !f.noIgnore {
+		if err := ig.Add(cwd, fswatch.CommonIgnores...); err != nil {
+			return nil, nil, err
+		}
+		for _, path := range f.paths {
+			tree, err := fswatch.LoadIgnoreTree(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			ig.Merge(tree)
+		}
+	}
+
+	symlinks, ok := map[string]fswatch.SymlinkPolicy{
+		"link":   fswatch.SymlinkLink,
+		"ignore": fswatch.SymlinkIgnore,
+		"follow": fswatch.SymlinkFollow,
+	}[f.symlinks]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown symlink policy %q, expected link, ignore or follow", f.symlinks)
+	}
+
+	opts := []func(*fswatch.Watcher){fswatch.WithIgnore(ig), fswatch.WithSymlinks(symlinks)}
+	if f.poll > 0 {
+		opts = append(opts, fswatch.WithPolling(f.poll))
+	}
+	if f.hash {
+		opts = append(opts, fswatch.WithContentHash(0))
+	}
+	if f.rescan {
+		opts = append(opts, fswatch.WithRescan())
+	}
+	w, err := fswatch.NewWatcher(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, path := range f.paths {
+		if err := w.AddRecursive(path); err != nil {
+			_ = w.Close()
+			return nil, nil, err
+		}
+	}
+
+	var watchOpts []func(*fswatch.WatchOpts)
+	if len(f.include) > 0 {
+		include, err := fswatch.NewIgnore(cwd, f.include...)
+		if err != nil {
+			_ = w.Close()
+			return nil, nil, err
+		}
+		watchOpts = append(watchOpts, fswatch.WithFilter(func(e fsnotify.Event) bool {
+			return include.Match(e.Name, false)
+		}))
+	}
+	return w, watchOpts, nil
+}
+
+// logf prints a message of fswatch itself, as opposed to the output of commands
+func logf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "[fswatch] "+format+"\n", args...)
+}
+
+// relative returns path relative to the working directory, if possible
+func relative(path string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, abs)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+func init() {
+	root.AddCommand(execCmd)
+}
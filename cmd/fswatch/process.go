@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// process runs a command in its own process group, so that it can be stopped with its children
+type process struct {
+	cmd  *exec.Cmd
+	done chan struct{} // closed when the command exits
+	err  error         // set before done is closed
+}
+
+// start starts a command with additional environment variables and open files,
+// which are inherited from file descriptor 3 onwards.
+// The standard output and error are the ones of fswatch. The standard input is too,
+// unless the command runs in its own process group: in the background, reading the terminal
+// would stop it (SIGTTIN), so it reads nothing instead.
+func start(args []string, env []string, files ...*os.File) (*process, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no command given")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), env...)
+	cmd.ExtraFiles = files
+	if !setProcessGroup(cmd) {
+		cmd.Stdin = os.Stdin
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &process{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// wait waits for the command to exit and returns its error
+func (p *process) wait() error {
+	<-p.done
+	return p.err
+}
+
+// exited returns true if the command exited
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop sends sig to the process group and waits for the command to exit.
+// After the grace period, the process group is killed.
+// It returns true if the command had to be killed.
+func (p *process) stop(sig os.Signal, grace time.Duration) bool {
+	if p.exited() {
+		return false
+	}
+	if err := signalGroup(p.cmd, sig); err != nil {
+		logf("error sending %v: %v", sig, err)
+	}
+
+	t := time.NewTimer(grace)
+	defer t.Stop()
+	select {
+	case <-p.done:
+		return false
+	case <-t.C:
+		if err := signalGroup(p.cmd, os.Kill); err != nil {
+			logf("error killing: %v", err)
+		}
+		<-p.done
+		return true
+	}
+}
+
+// status describes how the command exited
+func (p *process) status() string {
+	var exitErr *exec.ExitError
+	switch {
+	case p.err == nil:
+		return "exited with status 0"
+	case errors.As(p.err, &exitErr) && exitErr.Exited():
+		return fmt.Sprintf("exited with status %d", exitErr.ExitCode())
+	default:
+		return p.err.Error()
+	}
+}
+
+// supervisor runs at most one command at a time
+type supervisor struct {
+	args   []string
+	signal os.Signal
+	grace  time.Duration
+
+	mu      sync.Mutex
+	current *process
+}
+
+// restart stops the running command, if any, and starts it again
+func (s *supervisor) restart(env []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current != nil && !s.current.exited() {
+		if killed := s.current.stop(s.signal, s.grace); killed {
+			logf("killed after %v", s.grace)
+		}
+	}
+	return s.start(env)
+}
+
+// rerun waits for the running command to exit, if any, and starts it again
+func (s *supervisor) rerun(ctx context.Context, env []string) error {
+	s.mu.Lock()
+	current := s.current
+	s.mu.Unlock()
+
+	if current != nil {
+		select {
+		case <-current.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.start(env)
+}
+
+func (s *supervisor) start(env []string) error {
+	p, err := start(s.args, env)
+	if err != nil {
+		s.current = nil
+		return err
+	}
+	s.current = p
+	go func() {
+		_ = p.wait()
+		logf("command %s", p.status())
+	}()
+	return nil
+}
+
+// shutdown stops the running command, if any
+func (s *supervisor) shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current != nil {
+		s.current.stop(s.signal, s.grace)
+	}
+}
+
+// parseSignal parses a signal name like "SIGTERM", "term" or "15"
+func parseSignal(s string) (os.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return signalNumber(n), nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	if sig, ok := signals[name]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unknown signal %q", s)
+}
@@ -0,0 +1,32 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Only killing is supported on other platforms
+var signals = map[string]os.Signal{
+	"INT":  os.Interrupt,
+	"KILL": os.Kill,
+	"TERM": os.Kill,
+}
+
+func signalNumber(n int) os.Signal {
+	if n == 2 {
+		return os.Interrupt
+	}
+	return os.Kill
+}
+
+// setProcessGroup does nothing and returns false: process groups are not supported
+func setProcessGroup(cmd *exec.Cmd) bool {
+	return false
+}
+
+// signalGroup sends a signal to the command only
+func signalGroup(cmd *exec.Cmd, sig os.Signal) error {
+	return cmd.Process.Signal(sig)
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var signals = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
+
+func signalNumber(n int) os.Signal {
+	return syscall.Signal(n)
+}
+
+// setProcessGroup makes the command the leader of a new process group, and returns true
+func setProcessGroup(cmd *exec.Cmd) bool {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	return true
+}
+
+// signalGroup sends a signal to the process group of the command
+func signalGroup(cmd *exec.Cmd, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return cmd.Process.Signal(sig)
+	}
+	err := syscall.Kill(-cmd.Process.Pid, s)
+	if err == syscall.ESRCH {
+		// already exited
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestProcessStop(t *testing.T) {
+	tests := []struct {
+		name         string
+		script       string
+		expectKilled bool
+	}{
+		{
+			name:   "stops on signal",
+			script: "sleep 10",
+		},
+		{
+			name:         "killed after grace period",
+			script:       "trap '' TERM; sleep 10",
+			expectKilled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := start([]string{"sh", "-c", tt.script}, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// let the shell install its trap
+			time.Sleep(100 * time.Millisecond)
+
+			start := time.Now()
+			killed := p.stop(syscall.SIGTERM, 200*time.Millisecond)
+			if killed != tt.expectKilled {
+				t.Errorf("expected killed=%v, got %v", tt.expectKilled, killed)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("expected the command to stop quickly, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestProcessStopGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+
+	// the child of the command must be stopped too
+	p, err := start([]string{"sh", "-c", "sleep 10 & echo $! > " + pidFile + "; wait"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var pid int
+	deadline := time.Now().Add(5 * time.Second)
+	for pid == 0 && time.Now().Before(deadline) {
+		b, _ := os.ReadFile(pidFile)
+		pid, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pid == 0 {
+		t.Fatal("timeout waiting for the child to start")
+	}
+
+	p.stop(syscall.SIGTERM, time.Second)
+
+	deadline = time.Now().Add(5 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected child %d to be stopped", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSupervisorEnv(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+
+	s := &supervisor{
+		args:   []string{"sh", "-c", "echo $FSWATCH_CHANGED_PATHS > " + out},
+		signal: syscall.SIGTERM,
+		grace:  time.Second,
+	}
+	if err := s.restart([]string{"FSWATCH_CHANGED_PATHS=a.go"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = s.current.wait()
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.TrimSpace(string(b)) != "a.go" {
+		t.Errorf("expected a.go, got %q", b)
+	}
+}
+
+// TestProcessStdin checks that a command in its own process group does not read the terminal,
+// which would stop it in the background
+func TestProcessStdin(t *testing.T) {
+	p, err := start([]string{"cat"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.cmd.Stdin != nil {
+		t.Errorf("expected no standard input, got %v", p.cmd.Stdin)
+	}
+
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		p.stop(syscall.SIGKILL, 0)
+		t.Fatal("expected the command to read nothing and exit")
+	}
+	if err := p.wait(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+// githooks installs commit-msg, pre-push and post-merge hooks
+// which call back into this binary:
+//
+//	commit-msg  validates the Conventional Commit format
+//	pre-push    refuses to push a version tag not greater than the previous release
+//	post-merge  prints the suggested next version
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gforien/go/pkg/githooks"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	err := root.Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+var (
+	prefixFlag string
+	typesFlag  []string
+)
+
+var root = &cobra.Command{
+	Use:          "githooks",
+	Short:        "Install and run git hooks for Conventional Commits and semver tags.",
+	SilenceUsage: true,
+}
+
+var install = &cobra.Command{
+	Use:   "install",
+	Short: "Install the hooks in the current repository (idempotent)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := githooks.Dir(".")
+		if err != nil {
+			return err
+		}
+		bin, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("error locating executable: %w", err)
+		}
+		if bin, err = filepath.EvalSymlinks(bin); err != nil {
+			return fmt.Errorf("error locating executable: %w", err)
+		}
+
+		command := []string{bin, "run", "--prefix", prefixFlag}
+		if len(typesFlag) > 0 {
+			command = append(command, "--types", strings.Join(typesFlag, ","))
+		}
+		if err := githooks.Install(dir, command); err != nil {
+			return err
+		}
+		fmt.Printf("installed %s in %s\n", strings.Join(githooks.Hooks, ", "), dir)
+		return nil
+	},
+}
+
+var uninstall = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the hooks from the current repository and restore previous ones",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := githooks.Dir(".")
+		if err != nil {
+			return err
+		}
+		if err := githooks.Uninstall(dir); err != nil {
+			return err
+		}
+		fmt.Printf("uninstalled %s from %s\n", strings.Join(githooks.Hooks, ", "), dir)
+		return nil
+	},
+}
+
+var run = &cobra.Command{
+	Use:   "run <hook> [args]",
+	Short: "Run a hook (called by git)",
+}
+
+var commitMsg = &cobra.Command{
+	Use:  "commit-msg <file>",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return githooks.CommitMsg(args[0], typesFlag...)
+	},
+}
+
+var prePush = &cobra.Command{
+	Use:  "pre-push <remote> <url>",
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := open()
+		if err != nil {
+			return err
+		}
+		return githooks.PrePush(repo, prefixFlag, os.Stdin)
+	},
+}
+
+var postMerge = &cobra.Command{
+	Use:  "post-merge <squash>",
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := open()
+		if err != nil {
+			return err
+		}
+		return githooks.PostMerge(repo, prefixFlag, os.Stdout)
+	},
+}
+
+func open() (*gogit.Repository, error) {
+	repo, err := gogit.PlainOpenWithOptions(".", &gogit.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error opening repository: %w", err)
+	}
+	return repo, nil
+}
+
+func init() {
+	root.PersistentFlags().StringVar(&prefixFlag, "prefix", "", "tag prefix, e.g. 'my/module/' in a monorepo")
+	install.Flags().StringSliceVar(&typesFlag, "types", nil, "allowed commit types (default: conventional types)")
+	run.PersistentFlags().StringSliceVar(&typesFlag, "types", nil, "allowed commit types (default: conventional types)")
+
+	run.AddCommand(commitMsg, prePush, postMerge)
+	root.AddCommand(install, uninstall, run)
+}
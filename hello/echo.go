@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxEchoBody is the size of the request body echoed at most
+const maxEchoBody = 64 << 10
+
+// echo is the request as received by the server, to debug what proxies in between changed
+type echo struct {
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Proto      string            `json:"proto"`
+	Host       string            `json:"host"`
+	RemoteAddr string            `json:"remote_addr"`
+	Headers    http.Header       `json:"headers"`
+	Forwarded  *forwarded        `json:"forwarded,omitempty"`
+	TLS        *echoTLS          `json:"tls,omitempty"`
+	Body       string            `json:"body"`
+	BodySize   int64             `json:"body_size"`
+	Truncated  bool              `json:"body_truncated,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+}
+
+// forwarded is what the X-Forwarded-* headers tell about the original request
+type forwarded struct {
+	For   []string `json:"for"` // from the client to the last proxy, which is the remote address
+	Proto string   `json:"proto,omitempty"`
+	Host  string   `json:"host,omitempty"`
+}
+
+type echoTLS struct {
+	ServerName    string `json:"server_name"`
+	Version       string `json:"version"`
+	CipherSuite   string `json:"cipher_suite"`
+	Protocol      string `json:"protocol,omitempty"` // negotiated with ALPN
+	ClientSubject string `json:"client_subject,omitempty"`
+}
+
+// newEcho reads the request, with its body up to [maxEchoBody]
+func newEcho(r *http.Request) (*echo, error) {
+	e := &echo{
+		Method:     r.Method,
+		URL:        r.RequestURI,
+		Proto:      r.Proto,
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
+		Headers:    r.Header,
+		Forwarded:  newForwarded(r),
+		TLS:        newEchoTLS(r.TLS),
+		Env:        map[string]string{},
+	}
+	if e.URL == "" {
+		// a request not read by a server
+		e.URL = r.URL.RequestURI()
+	}
+
+	if r.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxEchoBody))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		rest, err := io.Copy(io.Discard, r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		e.Body = string(body)
+		e.BodySize = int64(len(body)) + rest
+		e.Truncated = rest > 0
+	}
+
+	for _, env := range podEnvs {
+		if val := os.Getenv(env); val != "" {
+			e.Env[env] = val
+		}
+	}
+	return e, nil
+}
+
+func newForwarded(r *http.Request) *forwarded {
+	xff := r.Header.Values("X-Forwarded-For")
+	proto := r.Header.Get("X-Forwarded-Proto")
+	host := r.Header.Get("X-Forwarded-Host")
+	if len(xff) == 0 && proto == "" && host == "" {
+		return nil
+	}
+
+	f := &forwarded{Proto: proto, Host: host}
+	for _, v := range xff {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				f.For = append(f.For, hop)
+			}
+		}
+	}
+	remote := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(remote); err == nil {
+		remote = h
+	}
+	f.For = append(f.For, remote)
+	return f
+}
+
+func newEchoTLS(cs *tls.ConnectionState) *echoTLS {
+	if cs == nil {
+		return nil
+	}
+	t := &echoTLS{
+		ServerName:  cs.ServerName,
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		Protocol:    cs.NegotiatedProtocol,
+	}
+	if len(cs.PeerCertificates) > 0 {
+		t.ClientSubject = cs.PeerCertificates[0].Subject.String()
+	}
+	return t
+}
+
+// write writes the echo as JSON or text, whichever accept prefers
+func (e *echo) write(w http.ResponseWriter, accept string) error {
+	var b []byte
+	if negotiate(accept, "text/plain", "application/json") == "application/json" {
+		w.Header().Set("Content-Type", "application/json")
+		var err error
+		if b, err = json.MarshalIndent(e, "", "  "); err != nil {
+			return fmt.Errorf("failed to encode response: %w", err)
+		}
+		b = append(b, '\n')
+	} else {
+		w.Header().Set("Content-Type", "text/plain")
+		b = []byte(e.String())
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+// String returns the request as it would be sent, followed by what the server knows about it
+func (e *echo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s\n", e.Method, e.URL, e.Proto)
+	fmt.Fprintf(&b, "Host: %s\n", e.Host)
+	for _, k := range sortedKeys(e.Headers) {
+		for _, v := range e.Headers[k] {
+			fmt.Fprintf(&b, "%s: %s\n", k, v)
+		}
+	}
+	b.WriteString("\n")
+	if e.Body != "" {
+		b.WriteString(e.Body)
+		if e.Truncated {
+			fmt.Fprintf(&b, "\n[truncated, %d bytes in total]", e.BodySize)
+		}
+		b.WriteString("\n\n")
+	}
+
+	fmt.Fprintf(&b, "remote address: %s\n", e.RemoteAddr)
+	if f := e.Forwarded; f != nil {
+		fmt.Fprintf(&b, "forwarded for: %s\n", strings.Join(f.For, ", "))
+		if f.Proto != "" {
+			fmt.Fprintf(&b, "forwarded proto: %s\n", f.Proto)
+		}
+		if f.Host != "" {
+			fmt.Fprintf(&b, "forwarded host: %s\n", f.Host)
+		}
+	}
+	if t := e.TLS; t != nil {
+		fmt.Fprintf(&b, "tls: %s, %s, server name %q\n", t.Version, t.CipherSuite, t.ServerName)
+		if t.Protocol != "" {
+			fmt.Fprintf(&b, "tls protocol: %s\n", t.Protocol)
+		}
+		if t.ClientSubject != "" {
+			fmt.Fprintf(&b, "tls client: %s\n", t.ClientSubject)
+		}
+	}
+	for _, k := range sortedKeys(e.Env) {
+		fmt.Fprintf(&b, "%s=%s\n", k, e.Env[k])
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// negotiate returns the offer preferred by the Accept header, or the first one if none is.
+// See https://www.rfc-editor.org/rfc/rfc9110#name-accept
+func negotiate(accept string, offers ...string) string {
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		q := 0.0
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || !matchMediaType(mediaType, offer) {
+				continue
+			}
+			pq := 1.0
+			if v, ok := params["q"]; ok {
+				if pq, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			q = max(q, pq)
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// matchMediaType returns true if the media range, like "text/*", matches the media type
+func matchMediaType(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	typ, _, _ := strings.Cut(mediaType, "/")
+	return mediaRange == typ+"/*"
+}
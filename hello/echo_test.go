@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEchoJSON(t *testing.T) {
+	t.Setenv("POD_NAME", "hello-0")
+	body := strings.Repeat("a", maxEchoBody+10)
+	req := httptest.NewRequest(http.MethodPut, "/foo?bar=baz", strings.NewReader(body))
+	req.RemoteAddr = "10.0.0.3:1234"
+	req.Header.Set("Accept", "text/html;q=0.9, application/json")
+	req.Header.Add("X-Forwarded-For", "203.0.113.1, 10.0.0.1")
+	req.Header.Add("X-Forwarded-For", "10.0.0.2")
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	rr := httptest.NewRecorder()
+	if err := httpHandler(rr, req); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected JSON content type, got %q", got)
+	}
+
+	var got echo
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("expected a JSON echo, got %v", err)
+	}
+	if got.Method != http.MethodPut || got.URL != "/foo?bar=baz" || got.Proto != "HTTP/1.1" {
+		t.Errorf("expected PUT /foo?bar=baz HTTP/1.1, got %s %s %s", got.Method, got.URL, got.Proto)
+	}
+	wantFor := []string{"203.0.113.1", "10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	if got.Forwarded == nil || !reflect.DeepEqual(got.Forwarded.For, wantFor) || got.Forwarded.Proto != "https" {
+		t.Errorf("expected forwarded for %v with proto https, got %+v", wantFor, got.Forwarded)
+	}
+	if len(got.Body) != maxEchoBody || got.BodySize != int64(len(body)) || !got.Truncated {
+		t.Errorf("expected body truncated to %d of %d bytes, got %d of %d (truncated %v)",
+			maxEchoBody, len(body), len(got.Body), got.BodySize, got.Truncated)
+	}
+	if got.Env["POD_NAME"] != "hello-0" {
+		t.Errorf("expected env POD_NAME=hello-0, got %v", got.Env)
+	}
+	if got.TLS != nil {
+		t.Errorf("expected no TLS, got %+v", got.TLS)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "", want: "text/plain"},
+		{accept: "*/*", want: "text/plain"},
+		{accept: "application/json", want: "application/json"},
+		{accept: "application/*", want: "application/json"},
+		{accept: "text/plain;q=0.5, application/json", want: "application/json"},
+		{accept: "text/*, application/json;q=0.8", want: "text/plain"},
+		{accept: "image/png", want: "text/plain"},
+		{accept: "application/json;q=0", want: "text/plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			if got := negotiate(tt.accept, "text/plain", "application/json"); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
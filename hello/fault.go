@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// faultNames are the settings of the faults, set with the flags --fault-<name>,
+// the environment variables HELLO_FAULT_<NAME>, or per request
+// with the query parameters fault-<name> or the headers X-Fault-<Name>.
+var faultNames = map[string]string{
+	"delay":         "latency added to the responses",
+	"delay-dist":    "distribution of the latency around --fault-delay: fixed, uniform, normal or lognormal",
+	"delay-jitter":  "spread of the latency: half-width if uniform, standard deviation if normal, sigma×delay if lognormal",
+	"error-percent": "percentage of responses replaced by an error",
+	"error-codes":   "status codes of the errors, picked at random (default 500)",
+	"reset-percent": "percentage of connections reset in the middle of the response",
+	"trickle":       "delay between each chunk of " + strconv.Itoa(trickleChunk) + " bytes of the response body",
+	"hang-percent":  "percentage of requests never answered, until the client gives up",
+}
+
+// trickleChunk is the size of the chunks of a trickled body
+const trickleChunk = 16
+
+// faults are the faults injected in the responses, to test the retries, timeouts
+// and circuit breakers of the clients and proxies
+type faults struct {
+	delay        time.Duration
+	dist         string
+	jitter       time.Duration
+	errorPercent float64
+	errorCodes   []int
+	resetPercent float64
+	trickle      time.Duration
+	hangPercent  float64
+
+	rand   func() float64 // uniform in [0, 1)
+	normal func() float64 // standard normal
+}
+
+func newFaults() *faults {
+	return &faults{
+		dist:       "fixed",
+		errorCodes: []int{http.StatusInternalServerError},
+		rand:       rand.Float64,
+		normal:     rand.NormFloat64,
+	}
+}
+
+// set sets the fault name from its string value
+func (f *faults) set(name, value string) error {
+	var err error
+	switch name {
+	case "delay":
+		f.delay, err = time.ParseDuration(value)
+	case "delay-dist":
+		switch value {
+		case "fixed", "uniform", "normal", "lognormal":
+			f.dist = value
+		default:
+			err = fmt.Errorf("expected fixed, uniform, normal or lognormal")
+		}
+	case "delay-jitter":
+		f.jitter, err = time.ParseDuration(value)
+	case "error-percent":
+		f.errorPercent, err = parsePercent(value)
+	case "error-codes":
+		var codes []int
+		for _, s := range strings.Split(value, ",") {
+			code, cerr := strconv.Atoi(strings.TrimSpace(s))
+			if cerr != nil || code < 100 || code > 999 {
+				err = fmt.Errorf("invalid status code %q", s)
+				break
+			}
+			codes = append(codes, code)
+		}
+		if err == nil {
+			f.errorCodes = codes
+		}
+	case "reset-percent":
+		f.resetPercent, err = parsePercent(value)
+	case "trickle":
+		f.trickle, err = time.ParseDuration(value)
+	case "hang-percent":
+		f.hangPercent, err = parsePercent(value)
+	default:
+		return fmt.Errorf("unknown fault %q", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid fault %s=%q: %w", name, value, err)
+	}
+	return nil
+}
+
+func parsePercent(s string) (float64, error) {
+	p, err := strconv.ParseFloat(s, 64)
+	if err == nil && (p < 0 || p > 100) {
+		err = fmt.Errorf("expected a percentage between 0 and 100")
+	}
+	return p, err
+}
+
+// override returns the faults of the request: f, with the settings
+// of its query parameters fault-<name> and headers X-Fault-<Name>
+func (f *faults) override(r *http.Request) (*faults, error) {
+	o := *f
+	query := r.URL.Query()
+	for name := range faultNames {
+		if v := r.Header.Get("X-Fault-" + name); v != "" {
+			if err := o.set(name, v); err != nil {
+				return nil, err
+			}
+		}
+		if v := query.Get("fault-" + name); v != "" {
+			if err := o.set(name, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &o, nil
+}
+
+// latency returns the delay to add to a response, following the distribution
+func (f *faults) latency() time.Duration {
+	d := float64(f.delay)
+	switch f.dist {
+	case "uniform":
+		d += (2*f.rand() - 1) * float64(f.jitter)
+	case "normal":
+		d += f.normal() * float64(f.jitter)
+	case "lognormal":
+		if f.delay > 0 {
+			// the delay is the median
+			d *= math.Exp(f.normal() * float64(f.jitter) / float64(f.delay))
+		}
+	}
+	return time.Duration(max(d, 0))
+}
+
+// chance returns true percent % of the time
+func (f *faults) chance(percent float64) bool {
+	return percent > 0 && f.rand()*100 < percent
+}
+
+// middleware injects the faults in the responses of next
+func (f *faults) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rf, err := f.override(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if rf.chance(rf.hangPercent) {
+			slog.Info("injecting fault", "fault", "hang", "path", r.URL.Path)
+			<-r.Context().Done()
+			return
+		}
+		if d := rf.latency(); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if rf.chance(rf.errorPercent) {
+			code := rf.errorCodes[int(rf.rand()*float64(len(rf.errorCodes)))]
+			slog.Info("injecting fault", "fault", "error", "status", code, "path", r.URL.Path)
+			w.Header().Set("X-Fault", "error")
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+
+		reset := rf.chance(rf.resetPercent)
+		if !reset && rf.trickle <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the response is buffered to be replayed slowly or partially
+		buf := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+		body := buf.body.Bytes()
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(buf.status)
+		if reset {
+			body = body[:len(body)/2]
+		}
+
+		rc := http.NewResponseController(w)
+		for len(body) > 0 {
+			n := min(len(body), trickleChunk)
+			if rf.trickle <= 0 {
+				n = len(body)
+			}
+			if _, err := w.Write(body[:n]); err != nil {
+				return
+			}
+			body = body[n:]
+			if rf.trickle > 0 {
+				_ = rc.Flush()
+				select {
+				case <-time.After(rf.trickle):
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+
+		if reset {
+			slog.Info("injecting fault", "fault", "reset", "path", r.URL.Path)
+			resetConn(rc)
+		}
+	})
+}
+
+// resetConn flushes the response and resets the connection with a TCP RST.
+// When the connection cannot be hijacked, as with HTTP/2, only the stream is reset.
+func resetConn(rc *http.ResponseController) {
+	_ = rc.Flush()
+	conn, _, err := rc.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		// discard the unsent data and send a RST rather than a FIN
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// bufferedResponse is a [http.ResponseWriter] keeping the response in memory
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) { b.status = status }
+
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
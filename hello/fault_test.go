@@ -0,0 +1,188 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaults(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		target      string
+		headers     map[string]string
+		wantStatus  int
+		wantBody    string
+		wantErr     bool
+		wantElapsed time.Duration // at least
+	}{
+		{
+			name:       "no fault",
+			target:     "/",
+			wantStatus: http.StatusOK,
+			wantBody:   "GET / HTTP/1.1",
+		},
+		{
+			name:       "error",
+			config:     map[string]string{"error-percent": "100"},
+			target:     "/",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "error codes from query",
+			target:     "/?fault-error-percent=100&fault-error-codes=503",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "error codes from headers",
+			target:     "/",
+			headers:    map[string]string{"X-Fault-Error-Percent": "100", "X-Fault-Error-Codes": "418"},
+			wantStatus: http.StatusTeapot,
+		},
+		{
+			name:       "query overrides config",
+			config:     map[string]string{"error-percent": "100"},
+			target:     "/?fault-error-percent=0",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid override",
+			target:     "/?fault-error-percent=120",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "invalid fault error-percent",
+		},
+		{
+			name:        "delay",
+			target:      "/?fault-delay=100ms",
+			wantStatus:  http.StatusOK,
+			wantElapsed: 100 * time.Millisecond,
+		},
+		{
+			name:        "trickle",
+			config:      map[string]string{"trickle": "10ms"},
+			target:      "/",
+			wantStatus:  http.StatusOK,
+			wantBody:    "GET / HTTP/1.1",
+			wantElapsed: 50 * time.Millisecond,
+		},
+		{
+			name:       "reset",
+			config:     map[string]string{"reset-percent": "100"},
+			target:     "/",
+			wantStatus: http.StatusOK,
+			wantErr:    true,
+		},
+		{
+			name:    "hang",
+			target:  "/?fault-hang-percent=100",
+			wantErr: true,
+		},
+		{
+			name:       "probes are not affected",
+			config:     map[string]string{"error-percent": "100"},
+			target:     "/livez",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "metrics are not affected",
+			config:     map[string]string{"error-percent": "100"},
+			target:     "/metrics",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "httpbin endpoints",
+			config:     map[string]string{"error-percent": "100", "error-codes": "503"},
+			target:     "/status/200",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "httpbin endpoints with overrides",
+			target:     "/headers?fault-error-percent=100&fault-error-codes=418",
+			wantStatus: http.StatusTeapot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFaults()
+			for name, v := range tt.config {
+				if err := f.set(name, v); err != nil {
+					t.Fatalf("error in test setup: %v", err)
+				}
+			}
+			srv := httptest.NewServer(handler(newHealth(), f))
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+tt.target, nil)
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			start := time.Now()
+			client := &http.Client{Timeout: time.Second}
+			res, err := client.Do(req)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(res.Body)
+				res.Body.Close()
+				if res.StatusCode != tt.wantStatus {
+					t.Errorf("expected status %d, got %d", tt.wantStatus, res.StatusCode)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("expected body to contain %q, got:\n%s", tt.wantBody, body)
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantElapsed {
+				t.Errorf("expected at least %s, got %s", tt.wantElapsed, elapsed)
+			}
+		})
+	}
+}
+
+func TestLatency(t *testing.T) {
+	tests := []struct {
+		dist   string
+		rand   float64
+		normal float64
+		want   time.Duration
+	}{
+		{dist: "fixed", rand: 0.9, normal: 2, want: 100 * time.Millisecond},
+		{dist: "uniform", rand: 0.75, want: 125 * time.Millisecond},
+		{dist: "uniform", rand: 0, want: 50 * time.Millisecond},
+		{dist: "normal", normal: -1, want: 50 * time.Millisecond},
+		{dist: "normal", normal: -3, want: 0},
+		{dist: "lognormal", normal: 0, want: 100 * time.Millisecond},
+		{dist: "lognormal", normal: 2, want: 271828182 * time.Nanosecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dist, func(t *testing.T) {
+			f := newFaults()
+			for name, v := range map[string]string{"delay": "100ms", "delay-jitter": "50ms", "delay-dist": tt.dist} {
+				if err := f.set(name, v); err != nil {
+					t.Fatalf("error in test setup: %v", err)
+				}
+			}
+			f.rand = func() float64 { return tt.rand }
+			f.normal = func() float64 { return tt.normal }
+
+			got := f.latency()
+			if diff := got - tt.want; diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log/slog"
@@ -19,6 +20,22 @@ import (
 // Options for [fetch], using functional options pattern.
 type fetchOpts struct {
 	resolve func(host string) string
+	tls     *tls.Config
+	proto   string
+}
+
+// withProto forces the protocol of the client, see [newTransport]
+func withProto(proto string) func(*fetchOpts) {
+	return func(opts *fetchOpts) {
+		opts.proto = proto
+	}
+}
+
+// withTLS sets the TLS configuration of the client, see [clientTLS]
+func withTLS(cfg *tls.Config) func(*fetchOpts) {
+	return func(opts *fetchOpts) {
+		opts.tls = cfg
+	}
 }
 
 func withResolve() func(*fetchOpts) {
@@ -56,16 +73,19 @@ func fetch(u *url.URL, opts ...(func(*fetchOpts))) (*http.Response, []byte) {
 		o(options)
 	}
 
-	if options.resolve != nil {
-		if ip := options.resolve(u.Hostname()); ip != u.Hostname() {
-			transport := &http.Transport{
-				DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+	if options.resolve != nil || options.tls != nil || options.proto != protoAuto {
+		var dial dialFunc
+		if options.resolve != nil {
+			if ip := options.resolve(u.Hostname()); ip != u.Hostname() {
+				dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
 					d := net.Dialer{Timeout: 5 * time.Second}
 					return d.DialContext(ctx, network, net.JoinHostPort(ip, u.Port()))
-				},
+				}
 			}
-			client = &http.Client{Transport: transport}
 		}
+		transport, err := newTransport(options.proto, options.tls, dial)
+		cobra.CheckErr(err)
+		client = &http.Client{Transport: transport}
 	}
 
 	req, err := http.NewRequest("GET", u.String(), nil)
@@ -88,6 +108,14 @@ func fetch(u *url.URL, opts ...(func(*fetchOpts))) (*http.Response, []byte) {
 		}
 	}()
 
+	fmt.Printf("* Using %s\n", res.Proto)
+	if res.TLS != nil {
+		for _, line := range tlsDetails(res.TLS) {
+			fmt.Printf("* %s\n", line)
+		}
+		fmt.Printf("*\n")
+	}
+
 	// read the entire response body and print it to stdout
 	dumpRes, err := httputil.DumpResponse(res, false)
 	cobra.CheckErr(err)
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// probeNames are the health probes served at /<name>
+var probeNames = []string{"healthz", "readyz", "livez"}
+
+// probeState is the state of a probe forced through the admin endpoint or a signal
+type probeState int
+
+const (
+	// stateAuto fails according to the configuration of the probe
+	stateAuto probeState = iota
+	stateUp
+	stateDown
+)
+
+func (s probeState) String() string {
+	switch s {
+	case stateUp:
+		return "up"
+	case stateDown:
+		return "down"
+	default:
+		return "auto"
+	}
+}
+
+func parseProbeState(s string) (probeState, error) {
+	switch s {
+	case "auto":
+		return stateAuto, nil
+	case "up":
+		return stateUp, nil
+	case "down":
+		return stateDown, nil
+	}
+	return stateAuto, fmt.Errorf("invalid probe state %q, expected auto, up or down", s)
+}
+
+// health holds the state of the health probes, which can be changed at runtime
+// to test how clients, load balancers and orchestrators react to failing instances.
+type health struct {
+	probes   map[string]*probe
+	draining atomic.Bool // when true, readyz fails
+	start    time.Time
+	now      func() time.Time
+	rand     func() float64
+}
+
+type probe struct {
+	mu          sync.Mutex
+	state       probeState
+	failAfter   time.Duration
+	failPercent float64
+}
+
+// Options for [newHealth], using functional options pattern.
+type healthOpts struct {
+	failAfter   map[string]time.Duration
+	failPercent map[string]float64
+}
+
+// withFailAfter makes the probe fail once the server has been running for d
+func withFailAfter(name string, d time.Duration) func(*healthOpts) {
+	return func(opts *healthOpts) {
+		opts.failAfter[name] = d
+	}
+}
+
+// withFailPercent makes the probe fail randomly, percent % of the time
+func withFailPercent(name string, percent float64) func(*healthOpts) {
+	return func(opts *healthOpts) {
+		opts.failPercent[name] = percent
+	}
+}
+
+func newHealth(opts ...func(*healthOpts)) *health {
+	options := &healthOpts{
+		failAfter:   map[string]time.Duration{},
+		failPercent: map[string]float64{},
+	}
+	for _, o := range opts {
+		o(options)
+	}
+
+	h := &health{
+		probes: map[string]*probe{},
+		start:  time.Now(),
+		now:    time.Now,
+		rand:   rand.Float64,
+	}
+	for _, name := range probeNames {
+		h.probes[name] = &probe{
+			failAfter:   options.failAfter[name],
+			failPercent: options.failPercent[name],
+		}
+	}
+	return h
+}
+
+// check returns why the probe fails, or nil if it passes
+func (h *health) check(name string) error {
+	if name == "readyz" && h.draining.Load() {
+		return fmt.Errorf("shutting down")
+	}
+
+	p := h.probes[name]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.state {
+	case stateUp:
+		return nil
+	case stateDown:
+		return fmt.Errorf("set down")
+	}
+	if p.failAfter > 0 && h.now().Sub(h.start) >= p.failAfter {
+		return fmt.Errorf("failing after %s", p.failAfter)
+	}
+	if p.failPercent > 0 && h.rand()*100 < p.failPercent {
+		return fmt.Errorf("failing %g%% of the time", p.failPercent)
+	}
+	return nil
+}
+
+// set forces the state of a probe
+func (h *health) set(name string, state probeState) {
+	p := h.probes[name]
+	p.mu.Lock()
+	p.state = state
+	p.mu.Unlock()
+	slog.Info("probe state changed", "probe", name, "state", state.String())
+}
+
+// toggle sets a probe down if it is not forced down, up otherwise.
+// It does not depend on the random failures and draining of [health.check].
+func (h *health) toggle(name string) {
+	p := h.probes[name]
+	p.mu.Lock()
+	state := stateDown
+	if p.state == stateDown {
+		state = stateUp
+	}
+	p.mu.Unlock()
+	h.set(name, state)
+}
+
+// drain makes readyz fail for good, so that no new traffic is routed to the server
+func (h *health) drain() {
+	h.draining.Store(true)
+	slog.Info("probe state changed", "probe", "readyz", "state", "draining")
+}
+
+// register adds the probe endpoints to mux, and the admin endpoints changing their state:
+//
+//	GET  /admin/probes                          lists the probes and their state
+//	POST /admin/probes/{probe}?state=up|down|auto  forces the state of a probe
+func (h *health) register(mux *http.ServeMux) {
+	for _, name := range probeNames {
+		mux.Handle("GET /"+name, h.probeHandler(name))
+	}
+	mux.Handle("GET /admin/probes", HandlerWithError(h.listHandler))
+	mux.Handle("POST /admin/probes/{probe}", HandlerWithError(h.setHandler))
+}
+
+func (h *health) probeHandler(name string) http.Handler {
+	return HandlerWithError(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "no-store")
+		body := "ok\n"
+		if err := h.check(name); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			body = name + " failed: " + err.Error() + "\n"
+		}
+		if _, err := io.WriteString(w, body); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+		return nil
+	})
+}
+
+func (h *health) listHandler(w http.ResponseWriter, r *http.Request) error {
+	var b strings.Builder
+	for _, name := range probeNames {
+		p := h.probes[name]
+		p.mu.Lock()
+		state := p.state
+		p.mu.Unlock()
+
+		status := "ok"
+		if err := h.check(name); err != nil {
+			status = err.Error()
+		}
+		fmt.Fprintf(&b, "%s: %s (%s)\n", name, state, status)
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+func (h *health) setHandler(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("probe")
+	if _, ok := h.probes[name]; !ok {
+		http.Error(w, "unknown probe "+name, http.StatusNotFound)
+		return nil
+	}
+	state, err := parseProbeState(r.FormValue("state"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	h.set(name, state)
+	return h.listHandler(w, r)
+}
+
+// handleSignals toggles the probes on the signals of [toggleSignals]
+func (h *health) handleSignals() {
+	ch := make(chan os.Signal, 1)
+	for sig := range toggleSignals {
+		signal.Notify(ch, sig)
+	}
+	for sig := range ch {
+		h.toggle(toggleSignals[sig])
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name       string
+		opts       []func(*healthOpts)
+		elapsed    time.Duration
+		rand       float64
+		setup      func(h *health)
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "healthy by default",
+			target:     "/livez",
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "fail after",
+			opts:       []func(*healthOpts){withFailAfter("livez", time.Minute)},
+			elapsed:    2 * time.Minute,
+			target:     "/livez",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "livez failed: failing after 1m0s",
+		},
+		{
+			name:       "not failing yet",
+			opts:       []func(*healthOpts){withFailAfter("livez", time.Minute)},
+			elapsed:    30 * time.Second,
+			target:     "/livez",
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "fail after applies to its probe only",
+			opts:       []func(*healthOpts){withFailAfter("livez", time.Minute)},
+			elapsed:    2 * time.Minute,
+			target:     "/readyz",
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "fail percent",
+			opts:       []func(*healthOpts){withFailPercent("healthz", 25)},
+			rand:       0.2,
+			target:     "/healthz",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "healthz failed: failing 25% of the time",
+		},
+		{
+			name:       "pass percent",
+			opts:       []func(*healthOpts){withFailPercent("healthz", 25)},
+			rand:       0.3,
+			target:     "/healthz",
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "set down",
+			setup:      func(h *health) { h.set("readyz", stateDown) },
+			target:     "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "readyz failed: set down",
+		},
+		{
+			name:       "set up overrides fail after",
+			opts:       []func(*healthOpts){withFailAfter("readyz", time.Minute)},
+			elapsed:    2 * time.Minute,
+			setup:      func(h *health) { h.set("readyz", stateUp) },
+			target:     "/readyz",
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "toggle",
+			setup:      func(h *health) { h.toggle("livez") },
+			target:     "/livez",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "livez failed: set down",
+		},
+		{
+			name: "toggle twice",
+			setup: func(h *health) {
+				h.toggle("livez")
+				h.toggle("livez")
+			},
+			target:     "/livez",
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "toggle ignores random failures",
+			opts:       []func(*healthOpts){withFailPercent("livez", 100)},
+			rand:       0.5,
+			setup:      func(h *health) { h.toggle("livez") },
+			target:     "/livez",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "livez failed: set down",
+		},
+		{
+			name: "toggle ignores draining",
+			setup: func(h *health) {
+				h.drain()
+				h.toggle("readyz")
+			},
+			target:     "/admin/probes",
+			wantStatus: http.StatusOK,
+			wantBody:   "healthz: auto (ok)\nreadyz: down (shutting down)\nlivez: auto (ok)",
+		},
+		{
+			name: "draining overrides set up",
+			setup: func(h *health) {
+				h.set("readyz", stateUp)
+				h.drain()
+			},
+			target:     "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "readyz failed: shutting down",
+		},
+		{
+			name:       "draining keeps the server alive",
+			setup:      func(h *health) { h.drain() },
+			target:     "/livez",
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHealth(tt.opts...)
+			h.now = func() time.Time { return h.start.Add(tt.elapsed) }
+			h.rand = func() float64 { return tt.rand }
+			if tt.setup != nil {
+				tt.setup(h)
+			}
+
+			rr := httptest.NewRecorder()
+			handler(h, newFaults()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			res := rr.Result()
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if got := strings.TrimSpace(string(body)); got != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestHealthAdmin(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBody   []string
+	}{
+		{
+			name:       "set down",
+			target:     "/admin/probes/readyz?state=down",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"healthz: auto (ok)", "readyz: down (set down)", "livez: auto (ok)"},
+		},
+		{
+			name:       "unknown probe",
+			target:     "/admin/probes/foo?state=down",
+			wantStatus: http.StatusNotFound,
+			wantBody:   []string{"unknown probe foo"},
+		},
+		{
+			name:       "invalid state",
+			target:     "/admin/probes/livez?state=sideways",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{`invalid probe state "sideways"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler(newHealth(), newFaults())
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, tt.target, nil))
+
+			res := rr.Result()
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			body, _ := io.ReadAll(res.Body)
+			for _, want := range tt.wantBody {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("expected body to contain %q, got:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
@@ -1,31 +1,152 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
-func serve() {
+// serve runs the HTTP server until a signal of [terminateSignals], then shuts it down gracefully
+func serve() error {
 	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
 
+	hc := newHealth(healthOptions()...)
+	go hc.handleSignals()
+	tlsConfig, err := serverTLS{
+		Cert:       viper.GetString("tls_cert"),
+		Key:        viper.GetString("tls_key"),
+		SelfSigned: viper.GetBool("tls_self_signed"),
+		SANs:       viper.GetStringSlice("tls_san"),
+		ClientCA:   viper.GetString("tls_client_ca"),
+	}.config()
+	if err != nil {
+		return err
+	}
+	f, err := newFaultsFromConfig()
+	if err != nil {
+		return err
+	}
+	conns := newConnTracker()
+	srv := &http.Server{
+		Handler:   handler(hc, f),
+		ConnState: conns.track,
+		TLSConfig: tlsConfig,
+	}
+	if err := configureHTTP2(srv, conns, viper.GetBool("http2"), viper.GetBool("h2c")); err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(viper.GetString("host"), viper.GetString("http_port"))
+	l, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("Listening at "+l.Addr().String(),
+		"tls", tlsConfig != nil,
+		"http2", tlsConfig != nil && viper.GetBool("http2"),
+		"h2c", viper.GetBool("h2c"),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), terminateSignals...)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			// the certificates are in the TLS config
+			errc <- srv.ServeTLS(l, "", "")
+		} else {
+			errc <- srv.Serve(l)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	// a second signal kills the server at once
+	stop()
+
+	_, _, err = shutdown(srv, conns, hc,
+		withPreStop(viper.GetDuration("pre_stop_delay")),
+		withDrainTimeout(viper.GetDuration("drain_timeout")),
+	)
+	return err
+}
+
+// handler returns the handler of the server, with its routes and middlewares.
+// The faults are injected in every endpoint but the probes and the metrics.
+func handler(hc *health, f *faults) http.Handler {
+	app := http.NewServeMux()
+	app.Handle("/", HandlerWithError(httpHandler))
+	newHTTPBin().register(app)
+
+	m := newMetrics()
 	mux := http.NewServeMux()
-	mux.Handle("/", HandlerWithError(httpHandler))
+	mux.Handle("/", f.middleware(app))
+	mux.Handle("GET /metrics", m)
+	hc.register(mux)
 
-	h := loggingMiddleware(coreHeaders(mux))
+	return loggingMiddleware(m.middleware(coreHeaders(mux)))
+}
 
-	addr := net.JoinHostPort(viper.GetString("host"), viper.GetString("http_port"))
-	slog.Info("Listening at " + addr)
+// healthOptions returns the options of the probes from the configuration
+func healthOptions() []func(*healthOpts) {
+	var opts []func(*healthOpts)
+	for _, name := range probeNames {
+		if d := viper.GetDuration(name + "_fail_after"); d > 0 {
+			opts = append(opts, withFailAfter(name, d))
+		}
+		if p := viper.GetFloat64(name + "_fail_percent"); p > 0 {
+			opts = append(opts, withFailPercent(name, p))
+		}
+	}
+	return opts
+}
+
+// newFaultsFromConfig returns the faults set with the flags --fault-<name>
+// and the environment variables HELLO_FAULT_<NAME>
+func newFaultsFromConfig() (*faults, error) {
+	f := newFaults()
+	for name := range faultNames {
+		if v := viper.GetString("fault_" + strings.ReplaceAll(name, "-", "_")); v != "" {
+			if err := f.set(name, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f, nil
+}
 
-	if err := http.ListenAndServe(addr, h); err != nil {
-		panic(err)
+// listen returns the listening socket inherited from the parent process, if any,
+// as with systemd socket activation (LISTEN_FDS) or 'fswatch dev --listen'.
+// Otherwise, it listens at addr.
+func listen(addr string) (net.Listener, error) {
+	if os.Getenv("LISTEN_FDS") != "1" {
+		return net.Listen("tcp", addr)
 	}
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		// meant for another process
+		return net.Listen("tcp", addr)
+	}
+
+	// inherited file descriptors start after stdin, stdout and stderr
+	f := os.NewFile(3, "listener")
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use inherited socket: %w", err)
+	}
+	return l, nil
 }
 
 func loggingMiddleware(next http.Handler) http.Handler {
@@ -37,12 +158,21 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		slog.Info("request",
 			"method", r.Method,
 			"path", r.URL.Path,
+			"proto", r.Proto,
 			"remote", r.RemoteAddr,
 			"duration", time.Since(start),
 		)
 	})
 }
 
+// podEnvs are the environment variables describing where the server runs,
+// as set with the Kubernetes downward API
+var podEnvs = []string{
+	"POD_NAME", "POD_IP",
+	"NODE_NAME", "NODE_IP",
+	"CONTAINER_PORT", "SVC", "SVC_PORT", "CLUSTER_IP", "EXTERNAL_IP",
+}
+
 // coreHeaders adds several headers
 func coreHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -51,12 +181,7 @@ func coreHeaders(next http.Handler) http.Handler {
 		if val := os.Getenv("POD_NAME"); val != "" {
 			w.Header().Set("X-Served-By", val)
 		}
-		envs := []string{
-			"POD_NAME", "POD_IP",
-			"NODE_NAME", "NODE_IP",
-			"CONTAINER_PORT", "SVC", "SVC_PORT", "CLUSTER_IP", "EXTERNAL_IP",
-		}
-		for _, env := range envs {
+		for _, env := range podEnvs {
 			if val := os.Getenv(env); val != "" {
 				w.Header().Set(toHeader(env), val)
 			}
@@ -86,15 +211,13 @@ func (h HandlerWithError) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// httpHandler echoes the request, see [echo]
 func httpHandler(w http.ResponseWriter, r *http.Request) error {
-	var err error
-
-	w.Header().Set("Content-Type", "text/plain")
-	w.WriteHeader(http.StatusOK)
-	if _, err = w.Write([]byte("OK")); err != nil {
-		return fmt.Errorf("failed to write response: %w", err)
+	e, err := newEcho(r)
+	if err != nil {
+		return err
 	}
-	return nil
+	return e.write(w, r.Header.Get("Accept"))
 }
 
 //nolint:unused
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Limits of the httpbin endpoints, so that a request cannot exhaust the server
+const (
+	maxDelay       = time.Minute
+	maxBytes       = 10 << 20
+	maxStreamLines = 100
+	maxRedirects   = 100
+)
+
+// httpbin serves utility endpoints in the spirit of https://httpbin.org,
+// with deterministic responses to test clients and proxies.
+type httpbin struct {
+	start time.Time // last modification time of the cached responses
+}
+
+func newHTTPBin() *httpbin {
+	// Last-Modified has a precision of one second
+	return &httpbin{start: time.Now().Truncate(time.Second)}
+}
+
+// register adds the endpoints to mux:
+//
+//	/status/{code}               responds with the status code
+//	/delay/{duration}            echoes the request after the duration, like "250ms" or "2" seconds
+//	GET /bytes/{n}               n random bytes, the same ones for a given ?seed=
+//	GET /stream/{n}              n lines of JSON, flushed one at a time
+//	GET /redirect/{n}            redirects n times, then to /
+//	GET /headers                 the headers of the request, as JSON
+//	GET /cookies                 the cookies of the request, as JSON
+//	GET /cookies/set?name=value  sets cookies, then redirects to /cookies
+//	GET /cookies/delete?name     deletes cookies, then redirects to /cookies
+//	GET /gzip                    echoes the request, gzip-encoded
+//	GET /cache/{seconds}         a response cacheable for seconds, with ETag and Last-Modified
+//	GET /basic-auth/{user}/{pass}  succeeds only with these basic auth credentials
+func (b *httpbin) register(mux *http.ServeMux) {
+	mux.Handle("/status/{code}", HandlerWithError(b.status))
+	mux.Handle("/delay/{duration}", HandlerWithError(b.delay))
+	mux.Handle("GET /bytes/{n}", HandlerWithError(b.bytes))
+	mux.Handle("GET /stream/{n}", HandlerWithError(b.stream))
+	mux.Handle("GET /redirect/{n}", HandlerWithError(b.redirect))
+	mux.Handle("GET /headers", HandlerWithError(b.headers))
+	mux.Handle("GET /cookies", HandlerWithError(b.cookies))
+	mux.Handle("GET /cookies/set", HandlerWithError(b.setCookies))
+	mux.Handle("GET /cookies/delete", HandlerWithError(b.deleteCookies))
+	mux.Handle("GET /gzip", HandlerWithError(b.gzip))
+	mux.Handle("GET /cache/{seconds}", HandlerWithError(b.cache))
+	mux.Handle("GET /basic-auth/{user}/{pass}", HandlerWithError(b.basicAuth))
+}
+
+func (b *httpbin) status(w http.ResponseWriter, r *http.Request) error {
+	code, err := pathInt(r, "code", 200, 599)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if code >= 300 && code < 400 {
+		w.Header().Set("Location", "/redirect/1")
+	}
+	if !bodyAllowed(code) {
+		w.WriteHeader(code)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(code)
+	if _, err := fmt.Fprintln(w, http.StatusText(code)); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+func (b *httpbin) delay(w http.ResponseWriter, r *http.Request) error {
+	s := r.PathValue("duration")
+	d, err := time.ParseDuration(s)
+	if seconds, serr := strconv.ParseFloat(s, 64); serr == nil {
+		d, err = time.Duration(seconds*float64(time.Second)), nil
+	}
+	if err != nil || d < 0 || d > maxDelay {
+		http.Error(w, fmt.Sprintf("invalid duration %q, expected up to %s", s, maxDelay), http.StatusBadRequest)
+		return nil
+	}
+
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+		return nil
+	}
+	return httpHandler(w, r)
+}
+
+func (b *httpbin) bytes(w http.ResponseWriter, r *http.Request) error {
+	n, err := pathInt(r, "n", 0, maxBytes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	seed := rand.Uint64()
+	if s := r.URL.Query().Get("seed"); s != "" {
+		if seed, err = strconv.ParseUint(s, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid seed %q", s), http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	src := rand.New(rand.NewPCG(seed, seed))
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(src.Uint32())
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+func (b *httpbin) stream(w http.ResponseWriter, r *http.Request) error {
+	n, err := pathInt(r, "n", 0, maxStreamLines)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	e, err := newEcho(r)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for i := range n {
+		line := struct {
+			ID int `json:"id"`
+			*echo
+		}{ID: i, echo: e}
+		if err := enc.Encode(line); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+		// each line is its own chunk
+		_ = rc.Flush()
+	}
+	return nil
+}
+
+func (b *httpbin) redirect(w http.ResponseWriter, r *http.Request) error {
+	n, err := pathInt(r, "n", 1, maxRedirects)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	location := "/"
+	if n > 1 {
+		location = "/redirect/" + strconv.Itoa(n-1)
+	}
+	http.Redirect(w, r, location, http.StatusFound)
+	return nil
+}
+
+func (b *httpbin) headers(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, http.StatusOK, map[string]http.Header{"headers": r.Header})
+}
+
+func (b *httpbin) cookies(w http.ResponseWriter, r *http.Request) error {
+	cookies := map[string]string{}
+	for _, c := range r.Cookies() {
+		cookies[c.Name] = c.Value
+	}
+	return writeJSON(w, http.StatusOK, map[string]map[string]string{"cookies": cookies})
+}
+
+func (b *httpbin) setCookies(w http.ResponseWriter, r *http.Request) error {
+	for name, values := range r.URL.Query() {
+		http.SetCookie(w, &http.Cookie{Name: name, Value: values[0], Path: "/"})
+	}
+	http.Redirect(w, r, "/cookies", http.StatusFound)
+	return nil
+}
+
+func (b *httpbin) deleteCookies(w http.ResponseWriter, r *http.Request) error {
+	for name := range r.URL.Query() {
+		http.SetCookie(w, &http.Cookie{Name: name, Path: "/", MaxAge: -1})
+	}
+	http.Redirect(w, r, "/cookies", http.StatusFound)
+	return nil
+}
+
+func (b *httpbin) gzip(w http.ResponseWriter, r *http.Request) error {
+	e, err := newEcho(r)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to compress response: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress response: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+// cache serves the same content for a given path, so that its ETag and Last-Modified
+// are stable and conditional requests get a 304 Not Modified
+func (b *httpbin) cache(w http.ResponseWriter, r *http.Request) error {
+	seconds, err := pathInt(r, "seconds", 0, 365*24*3600)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	data, err := json.MarshalIndent(map[string]any{
+		"path":          r.URL.Path,
+		"max_age":       seconds,
+		"last_modified": b.start.UTC().Format(http.TimeFormat),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	data = append(data, '\n')
+	sum := sha256.Sum256(data)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(seconds))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	// handles If-None-Match, If-Modified-Since and ranges
+	http.ServeContent(w, r, "", b.start, bytes.NewReader(data))
+	return nil
+}
+
+func (b *httpbin) basicAuth(w http.ResponseWriter, r *http.Request) error {
+	wantUser, wantPass := r.PathValue("user"), r.PathValue("pass")
+	user, pass, ok := r.BasicAuth()
+	if !ok ||
+		subtle.ConstantTimeCompare([]byte(user), []byte(wantUser)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(pass), []byte(wantPass)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="hello"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return nil
+	}
+	return writeJSON(w, http.StatusOK, map[string]any{"authenticated": true, "user": user})
+}
+
+// bodyAllowed returns true if a response with this status may have a body,
+// see https://www.rfc-editor.org/rfc/rfc9110#section-6.4.1
+func bodyAllowed(code int) bool {
+	return code >= 200 && code != http.StatusNoContent && code != http.StatusNotModified
+}
+
+// pathInt returns the path value name as an integer between lo and hi
+func pathInt(r *http.Request, name string, lo, hi int) (int, error) {
+	s := r.PathValue(name)
+	n, err := strconv.Atoi(s)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("invalid %s %q, expected an integer between %d and %d", name, s, lo, hi)
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a [bytes.Buffer] safe for concurrent use, to collect the logs of a server
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHTTPBin(t *testing.T) {
+	// the errors of the handlers and of the server are collected
+	logs := &lockedBuffer{}
+	slog.SetDefault(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	srv := httptest.NewUnstartedServer(handler(newHealth(), newFaults()))
+	srv.Config.ErrorLog = log.New(logs, "", 0)
+	srv.Start()
+	defer srv.Close()
+
+	// the Location and Set-Cookie headers are checked rather than followed
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: &http.Transport{DisableCompression: true},
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		headers    map[string]string
+		user, pass string
+		wantStatus int
+		wantHeader map[string]string
+		wantBody   []string
+		wantLen    int
+	}{
+		{name: "status", method: http.MethodPost, target: "/status/418", wantStatus: http.StatusTeapot, wantBody: []string{"I'm a teapot"}},
+		{name: "status no content", target: "/status/204", wantStatus: http.StatusNoContent},
+		{name: "status not modified", target: "/status/304", wantStatus: http.StatusNotModified},
+		{name: "status redirect", target: "/status/301", wantStatus: http.StatusMovedPermanently, wantHeader: map[string]string{"Location": "/redirect/1"}},
+		{name: "invalid status", target: "/status/42", wantStatus: http.StatusBadRequest},
+		{name: "delay", target: "/delay/50ms", wantStatus: http.StatusOK, wantBody: []string{"GET /delay/50ms"}},
+		{name: "delay in seconds", target: "/delay/0.05", wantStatus: http.StatusOK},
+		{name: "delay too long", target: "/delay/2h", wantStatus: http.StatusBadRequest},
+		{name: "bytes", target: "/bytes/1000", wantStatus: http.StatusOK, wantLen: 1000},
+		{name: "stream", target: "/stream/3", wantStatus: http.StatusOK, wantBody: []string{`{"id":0,`, `{"id":2,`}},
+		{name: "redirect", target: "/redirect/3", wantStatus: http.StatusFound, wantHeader: map[string]string{"Location": "/redirect/2"}},
+		{name: "last redirect", target: "/redirect/1", wantStatus: http.StatusFound, wantHeader: map[string]string{"Location": "/"}},
+		{name: "headers", target: "/headers", headers: map[string]string{"X-Custom-Header": "abc123"}, wantStatus: http.StatusOK, wantBody: []string{`"X-Custom-Header": [`, `"abc123"`}},
+		{name: "cookies", target: "/cookies", headers: map[string]string{"Cookie": "a=1; b=2"}, wantStatus: http.StatusOK, wantBody: []string{`"a": "1"`, `"b": "2"`}},
+		{name: "set cookies", target: "/cookies/set?a=1", wantStatus: http.StatusFound, wantHeader: map[string]string{"Set-Cookie": "a=1; Path=/", "Location": "/cookies"}},
+		{name: "delete cookies", target: "/cookies/delete?a", wantStatus: http.StatusFound, wantHeader: map[string]string{"Set-Cookie": "a=; Path=/; Max-Age=0"}},
+		{name: "gzip", target: "/gzip", wantStatus: http.StatusOK, wantHeader: map[string]string{"Content-Encoding": "gzip"}},
+		{name: "cache", target: "/cache/60", wantStatus: http.StatusOK, wantHeader: map[string]string{"Cache-Control": "public, max-age=60"}, wantBody: []string{`"max_age": 60`}},
+		{name: "cache not modified", target: "/cache/60", headers: map[string]string{"If-Modified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}, wantStatus: http.StatusNotModified},
+		{name: "basic auth", target: "/basic-auth/alice/secret", user: "alice", pass: "secret", wantStatus: http.StatusOK, wantBody: []string{`"user": "alice"`}},
+		{name: "basic auth wrong password", target: "/basic-auth/alice/secret", user: "alice", pass: "guess", wantStatus: http.StatusUnauthorized, wantHeader: map[string]string{"WWW-Authenticate": `Basic realm="hello"`}},
+		{name: "basic auth missing", target: "/basic-auth/alice/secret", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequest(method, srv.URL+tt.target, nil)
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			defer res.Body.Close()
+			body, _ := io.ReadAll(res.Body)
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			for k, v := range tt.wantHeader {
+				if got := res.Header.Get(k); got != v {
+					t.Errorf("expected header %s=%q, got %q", k, v, got)
+				}
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("expected body to contain %q, got:\n%s", want, body)
+				}
+			}
+			if tt.wantLen > 0 && len(body) != tt.wantLen {
+				t.Errorf("expected %d bytes, got %d", tt.wantLen, len(body))
+			}
+		})
+	}
+
+	srv.Close()
+	if got := logs.String(); got != "" {
+		t.Errorf("expected no errors logged, got:\n%s", got)
+	}
+}
+
+func TestHTTPBinDeterministic(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	h := handler(newHealth(), newFaults())
+
+	get := func(target string, headers map[string]string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+	read := func(res *http.Response) []byte {
+		b, _ := io.ReadAll(res.Body)
+		return b
+	}
+
+	// seeded bytes
+	a, b := read(get("/bytes/64?seed=42", nil)), read(get("/bytes/64?seed=42", nil))
+	if !bytes.Equal(a, b) {
+		t.Errorf("expected the same bytes for the same seed, got %x and %x", a, b)
+	}
+	if c := read(get("/bytes/64?seed=43", nil)); bytes.Equal(a, c) {
+		t.Errorf("expected different bytes for another seed, got %x twice", a)
+	}
+
+	// the ETag of a cached response revalidates it
+	etag := get("/cache/10", nil).Header.Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected an ETag, got none")
+	}
+	if res := get("/cache/10", map[string]string{"If-None-Match": etag}); res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, res.StatusCode)
+	}
+	if res := get("/cache/20", map[string]string{"If-None-Match": etag}); res.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d for another path, got %d", http.StatusOK, res.StatusCode)
+	}
+}
@@ -50,6 +50,23 @@ var (
 	listenFlag   bool // when true, act as a server
 	resolveFlag  bool
 	stdinFlag    bool
+
+	drainTimeoutFlag time.Duration
+	preStopDelayFlag time.Duration
+
+	tlsCertFlag       string
+	tlsKeyFlag        string
+	tlsSelfSignedFlag bool
+	tlsSANFlag        []string
+	tlsClientCAFlag   string
+	caCertFlag        string
+	certFlag          string
+	keyFlag           string
+	insecureFlag      bool
+	sniFlag           string
+	http2Flag         bool
+	h2cFlag           bool
+	protoFlag         string
 )
 
 var root = &cobra.Command{
@@ -65,8 +82,9 @@ var root = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if viper.GetBool("listen") {
 			if viper.GetBool("http") {
-				serve()
-				return nil
+				// errors from here on are not usage errors
+				cmd.SilenceUsage = true
+				return serve()
 			}
 		}
 
@@ -85,7 +103,11 @@ var root = &cobra.Command{
 				u.Scheme = "http"
 			}
 			if u.Port() == "" {
-				u.Host = net.JoinHostPort(u.Hostname(), viper.GetString("http_port"))
+				port := viper.GetString("http_port")
+				if u.Scheme == "https" && !viper.IsSet("http_port") {
+					port = "443"
+				}
+				u.Host = net.JoinHostPort(u.Hostname(), port)
 			}
 			fmt.Printf("Fetching %s\n", u.String())
 
@@ -93,6 +115,25 @@ var root = &cobra.Command{
 			if viper.GetBool("resolve") {
 				opts = append(opts, withResolve())
 			}
+			tlsConfig, err := clientTLS{
+				CACert:   viper.GetString("cacert"),
+				Cert:     viper.GetString("cert"),
+				Key:      viper.GetString("key"),
+				Insecure: viper.GetBool("insecure"),
+				SNI:      viper.GetString("sni"),
+			}.config()
+			if err != nil {
+				return err
+			}
+			if tlsConfig != nil {
+				opts = append(opts, withTLS(tlsConfig))
+			}
+			if proto := viper.GetString("proto"); proto != protoAuto {
+				if _, err := newTransport(proto, nil, nil); err != nil {
+					return err
+				}
+				opts = append(opts, withProto(proto))
+			}
 
 			for {
 				_, _ = fetch(u, opts...)
@@ -135,6 +176,28 @@ func init() {
 	root.Flags().BoolVarP(&listenFlag, "listen", "l", false, "act as a server")
 	root.Flags().BoolVar(&resolveFlag, "resolve", false, "execute a new DNS resolution each time")
 	root.Flags().BoolVar(&stdinFlag, "stdin", false, "hello stdin")
+	root.Flags().DurationVar(&drainTimeoutFlag, "drain-timeout", 30*time.Second, "on shutdown, time to wait for in-flight requests before closing their connections")
+	root.Flags().DurationVar(&preStopDelayFlag, "pre-stop-delay", 0, "on shutdown, time to keep serving with /readyz failing")
+	root.Flags().StringVar(&tlsCertFlag, "tls-cert", "", "server: serve HTTPS with this PEM certificate")
+	root.Flags().StringVar(&tlsKeyFlag, "tls-key", "", "server: PEM key of --tls-cert")
+	root.Flags().BoolVar(&tlsSelfSignedFlag, "tls-self-signed", false, "server: serve HTTPS with a generated self-signed certificate")
+	root.Flags().StringSliceVar(&tlsSANFlag, "tls-san", defaultSANs, "server: DNS names and IPs of the self-signed certificate")
+	root.Flags().StringVar(&tlsClientCAFlag, "tls-client-ca", "", "server: require client certificates verified by this PEM CA bundle (mTLS)")
+	root.Flags().StringVar(&caCertFlag, "cacert", "", "client: verify the server certificate with this PEM CA bundle")
+	root.Flags().StringVar(&certFlag, "cert", "", "client: PEM client certificate (mTLS)")
+	root.Flags().StringVar(&keyFlag, "key", "", "client: PEM key of --cert")
+	root.Flags().BoolVarP(&insecureFlag, "insecure", "k", false, "client: do not verify the server certificate")
+	root.Flags().StringVar(&sniFlag, "sni", "", "client: server name to send and verify, instead of the host")
+	root.Flags().BoolVar(&http2Flag, "http2", true, "server: serve HTTP/2 over TLS to the clients negotiating it")
+	root.Flags().BoolVar(&h2cFlag, "h2c", false, "server: serve HTTP/2 over cleartext, with prior knowledge or Upgrade")
+	root.Flags().StringVar(&protoFlag, "proto", "", "client: force the protocol, http1.1, h2 or h2c")
+	for name, usage := range faultNames {
+		root.Flags().String("fault-"+name, "", "server: "+usage)
+	}
+	for _, name := range probeNames {
+		root.Flags().Duration(name+"-fail-after", 0, "fail /"+name+" once running for this duration")
+		root.Flags().Float64(name+"-fail-percent", 0, "fail /"+name+" randomly this percentage of the time")
+	}
 
 	viper.SetEnvPrefix("hello")
 	cobra.CheckErr(viper.BindPFlag("cli", root.Flags().Lookup("cli")))
@@ -144,5 +207,22 @@ func init() {
 	cobra.CheckErr(viper.BindPFlag("listen", root.Flags().Lookup("listen")))
 	cobra.CheckErr(viper.BindPFlag("resolve", root.Flags().Lookup("resolve")))
 	cobra.CheckErr(viper.BindPFlag("stdin", root.Flags().Lookup("stdin")))
+	cobra.CheckErr(viper.BindPFlag("drain_timeout", root.Flags().Lookup("drain-timeout")))
+	cobra.CheckErr(viper.BindPFlag("pre_stop_delay", root.Flags().Lookup("pre-stop-delay")))
+	for _, key := range []string{
+		"tls-cert", "tls-key", "tls-self-signed", "tls-san", "tls-client-ca",
+		"cacert", "cert", "key", "insecure", "sni",
+		"http2", "h2c", "proto",
+	} {
+		cobra.CheckErr(viper.BindPFlag(strings.ReplaceAll(key, "-", "_"), root.Flags().Lookup(key)))
+	}
+	for name := range faultNames {
+		key := "fault-" + name
+		cobra.CheckErr(viper.BindPFlag(strings.ReplaceAll(key, "-", "_"), root.Flags().Lookup(key)))
+	}
+	for _, name := range probeNames {
+		cobra.CheckErr(viper.BindPFlag(name+"_fail_after", root.Flags().Lookup(name+"-fail-after")))
+		cobra.CheckErr(viper.BindPFlag(name+"_fail_percent", root.Flags().Lookup(name+"-fail-percent")))
+	}
 	viper.AutomaticEnv()
 }
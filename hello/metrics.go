@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds of the latency histogram, in seconds
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metrics are the metrics of the HTTP server, served in the Prometheus text format.
+// See https://prometheus.io/docs/instrumenting/exposition_formats/
+//
+// Requests are labelled by the pattern of the route which served them rather than by path,
+// so that arbitrary paths do not create arbitrary many series.
+type metrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	bytes     map[requestKey]uint64
+	durations map[routeKey]*histogram
+	inFlight  int64
+}
+
+type routeKey struct {
+	method string
+	path   string
+}
+
+type requestKey struct {
+	routeKey
+	status int
+}
+
+type histogram struct {
+	counts []uint64 // by bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  map[requestKey]uint64{},
+		bytes:     map[requestKey]uint64{},
+		durations: map[routeKey]*histogram{},
+	}
+}
+
+// middleware records the requests served by next
+func (m *metrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		m.mu.Lock()
+		m.inFlight++
+		m.mu.Unlock()
+
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			// r.Pattern is set by the ServeMux, as "[METHOD ][HOST]/[PATH]"
+			route := routeKey{method: r.Method, path: r.Pattern}
+			if _, path, ok := strings.Cut(route.path, " "); ok {
+				route.path = path
+			}
+			if route.path == "" {
+				route.path = "unmatched"
+			}
+			m.observe(route, rec.statusCode(), rec.written, time.Since(start))
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+func (m *metrics) observe(route routeKey, status int, written int64, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight--
+	key := requestKey{routeKey: route, status: status}
+	m.requests[key]++
+	m.bytes[key] += uint64(written)
+
+	h, ok := m.durations[route]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		m.durations[route] = h
+	}
+	seconds := d.Seconds()
+	for i, le := range durationBuckets {
+		if seconds <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write writes the metrics in the Prometheus text format, sorted by labels
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	header(&b, "hello_build_info", "gauge", "Build information of hello, always 1.")
+	fmt.Fprintf(&b, "hello_build_info{version=%s,commit=%s,date=%s} 1\n",
+		quote(version), quote(commit), quote(date))
+
+	header(&b, "hello_http_requests_in_flight", "gauge", "Number of HTTP requests being served.")
+	fmt.Fprintf(&b, "hello_http_requests_in_flight %d\n", m.inFlight)
+
+	header(&b, "hello_http_requests_total", "counter", "Number of HTTP requests served.")
+	for _, k := range sortedRequests(m.requests) {
+		fmt.Fprintf(&b, "hello_http_requests_total{%s} %d\n", k.labels(), m.requests[k])
+	}
+
+	header(&b, "hello_http_response_bytes_total", "counter", "Number of bytes written in HTTP response bodies.")
+	for _, k := range sortedRequests(m.bytes) {
+		fmt.Fprintf(&b, "hello_http_response_bytes_total{%s} %d\n", k.labels(), m.bytes[k])
+	}
+
+	header(&b, "hello_http_request_duration_seconds", "histogram", "Duration of HTTP requests.")
+	routes := make([]routeKey, 0, len(m.durations))
+	for k := range m.durations {
+		routes = append(routes, k)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].less(routes[j]) })
+	for _, k := range routes {
+		h := m.durations[k]
+		var cumulative uint64
+		for i, le := range durationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "hello_http_request_duration_seconds_bucket{%s,le=%s} %d\n",
+				k.labels(), quote(strconv.FormatFloat(le, 'g', -1, 64)), cumulative)
+		}
+		fmt.Fprintf(&b, "hello_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", k.labels(), h.count)
+		fmt.Fprintf(&b, "hello_http_request_duration_seconds_sum{%s} %s\n", k.labels(), strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "hello_http_request_duration_seconds_count{%s} %d\n", k.labels(), h.count)
+	}
+
+	_, _ = io.WriteString(w, b.String())
+}
+
+func header(b *strings.Builder, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (k routeKey) labels() string {
+	return "method=" + quote(k.method) + ",path=" + quote(k.path)
+}
+
+func (k routeKey) less(o routeKey) bool {
+	if k.path != o.path {
+		return k.path < o.path
+	}
+	return k.method < o.method
+}
+
+func (k requestKey) labels() string {
+	return k.routeKey.labels() + ",status=" + quote(strconv.Itoa(k.status))
+}
+
+func sortedRequests(m map[requestKey]uint64) []requestKey {
+	keys := make([]requestKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].routeKey != keys[j].routeKey {
+			return keys[i].routeKey.less(keys[j].routeKey)
+		}
+		return keys[i].status < keys[j].status
+	})
+	return keys
+}
+
+// quote returns a label value, quoted and escaped
+func quote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// responseRecorder records the status code and the number of bytes of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.written += int64(n)
+	return n, err
+}
+
+// Unwrap lets [http.ResponseController] reach the underlying writer, e.g. to flush
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		// nothing written
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	h := handler(newHealth(), newFaults())
+
+	for _, target := range []string{"/", "/foo", "/metrics"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/metrics", nil))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	res := rr.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	if got := res.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("expected Prometheus content type, got %q", got)
+	}
+	data, _ := io.ReadAll(res.Body)
+	dump := string(data)
+
+	wantLines := []string{
+		`# TYPE hello_http_requests_total counter`,
+		`hello_build_info{version="dev",commit="none",date="none"} 1`,
+		// the request being served
+		`hello_http_requests_in_flight 1`,
+		// both paths are served by the "/" route
+		`hello_http_requests_total{method="GET",path="/",status="200"} 2`,
+		`hello_http_requests_total{method="GET",path="/metrics",status="200"} 1`,
+		`hello_http_requests_total{method="POST",path="/",status="200"} 1`,
+		`hello_http_request_duration_seconds_bucket{method="GET",path="/",le="+Inf"} 2`,
+		`hello_http_request_duration_seconds_count{method="GET",path="/"} 2`,
+		`# TYPE hello_http_request_duration_seconds histogram`,
+	}
+	lines := strings.Split(dump, "\n")
+	for _, want := range wantLines {
+		found := false
+		for _, line := range lines {
+			if line == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected line %q, got:\n%s", want, dump)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Protocols the client can force with [withProto]
+const (
+	protoAuto   = ""        // HTTP/2 if negotiated with TLS ALPN, HTTP/1.1 otherwise
+	protoHTTP11 = "http1.1" // HTTP/1.1 only
+	protoH2     = "h2"      // HTTP/2 over TLS only
+	protoH2C    = "h2c"     // HTTP/2 over cleartext, with prior knowledge
+)
+
+// dialFunc dials the server, see [http.Transport.DialContext]
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// newTransport returns a transport speaking proto, using dial if not nil
+func newTransport(proto string, tlsConfig *tls.Config, dial dialFunc) (http.RoundTripper, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	switch proto {
+	case protoAuto:
+		return &http.Transport{
+			DialContext:       dial,
+			TLSClientConfig:   tlsConfig,
+			ForceAttemptHTTP2: true,
+		}, nil
+
+	case protoHTTP11:
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		return &http.Transport{
+			DialContext:     dial,
+			TLSClientConfig: tlsConfig,
+			// a non-nil empty map disables HTTP/2
+			TLSNextProto: map[string]func(string, *tls.Conn) http.RoundTripper{},
+		}, nil
+
+	case protoH2:
+		return &http2.Transport{
+			TLSClientConfig: tlsConfig,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+				conn, err := dial(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				tlsConn := tls.Client(conn, cfg)
+				if err := tlsConn.HandshakeContext(ctx); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				if p := tlsConn.ConnectionState().NegotiatedProtocol; p != http2.NextProtoTLS {
+					conn.Close()
+					return nil, fmt.Errorf("server does not support HTTP/2 over TLS (ALPN %q)", p)
+				}
+				return tlsConn, nil
+			},
+		}, nil
+
+	case protoH2C:
+		return &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid protocol %q, expected %s, %s or %s", proto, protoHTTP11, protoH2, protoH2C)
+}
+
+// configureHTTP2 serves HTTP/2 over TLS to the clients negotiating it if overTLS,
+// and over cleartext to the clients asking for it if cleartext,
+// either with prior knowledge or with an Upgrade from HTTP/1.1.
+//
+// The h2c connections are hijacked from srv: they are tracked by conns,
+// and told to go away when srv shuts down, so that [shutdown] drains them too.
+func configureHTTP2(srv *http.Server, conns *connTracker, overTLS, cleartext bool) error {
+	if !cleartext {
+		if !overTLS {
+			// a non-nil empty map disables HTTP/2 over TLS
+			srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		return nil
+	}
+
+	// the HTTP/2 server is configured for TLS too, to register its shutdown hook
+	tlsConfig := srv.TLSConfig
+	if tlsConfig != nil {
+		srv.TLSConfig = tlsConfig.Clone()
+	}
+	h2s := &http2.Server{}
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		return err
+	}
+	if !overTLS || tlsConfig == nil {
+		srv.TLSConfig = tlsConfig
+	}
+	if !overTLS {
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+
+	next := h2c.NewHandler(srv.Handler, h2s)
+	srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hw := &h2cHijacker{ResponseWriter: w, conns: conns}
+		defer hw.untrack()
+		next.ServeHTTP(hw, r)
+	})
+	return nil
+}
+
+// h2cHijacker tracks the connection hijacked to serve h2c, while it is served
+type h2cHijacker struct {
+	http.ResponseWriter
+	conns *connTracker
+	conn  net.Conn
+}
+
+func (h *h2cHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(h.ResponseWriter).Hijack()
+	if err == nil {
+		h.conn = conn
+		h.conns.trackH2C(conn)
+	}
+	return conn, rw, err
+}
+
+func (h *h2cHijacker) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
+
+func (h *h2cHijacker) untrack() {
+	if h.conn != nil {
+		h.conns.untrackH2C(h.conn)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProto(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name      string
+		tls       bool
+		http2     bool
+		h2c       bool
+		proto     string
+		wantProto string
+		wantErr   bool
+	}{
+		{name: "cleartext", wantProto: "HTTP/1.1"},
+		{name: "cleartext HTTP/1.1", h2c: true, proto: protoHTTP11, wantProto: "HTTP/1.1"},
+		{name: "h2c", h2c: true, proto: protoH2C, wantProto: "HTTP/2.0"},
+		{name: "h2c not served", proto: protoH2C, wantErr: true},
+		{name: "h2 over cleartext", h2c: true, proto: protoH2, wantErr: true},
+		{name: "TLS negotiated", tls: true, http2: true, wantProto: "HTTP/2.0"},
+		{name: "TLS HTTP/1.1", tls: true, http2: true, proto: protoHTTP11, wantProto: "HTTP/1.1"},
+		{name: "TLS h2", tls: true, http2: true, proto: protoH2, wantProto: "HTTP/2.0"},
+		{name: "TLS without HTTP/2", tls: true, wantProto: "HTTP/1.1"},
+		{name: "TLS h2 not served", tls: true, proto: protoH2, wantErr: true},
+		{name: "TLS h2 not served with h2c", tls: true, h2c: true, proto: protoH2, wantErr: true},
+		{name: "TLS h2 with h2c", tls: true, http2: true, h2c: true, proto: protoH2, wantProto: "HTTP/2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &http.Server{
+				Handler:  handler(newHealth(), newFaults()),
+				ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+			}
+			var err error
+			if tt.tls {
+				if srv.TLSConfig, err = (serverTLS{SelfSigned: true}).config(); err != nil {
+					t.Fatalf("error in test setup: %v", err)
+				}
+			}
+			if err := configureHTTP2(srv, newConnTracker(), tt.http2, tt.h2c); err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			defer srv.Close()
+
+			url := "http://" + l.Addr().String()
+			var clientTLS *tls.Config
+			if tt.tls {
+				go srv.ServeTLS(l, "", "") //nolint:errcheck
+				url = "https://" + l.Addr().String()
+				clientTLS = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+			} else {
+				go srv.Serve(l) //nolint:errcheck
+			}
+
+			transport, err := newTransport(tt.proto, clientTLS, nil)
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			res, err := (&http.Client{Transport: transport}).Get(url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			if res.Proto != tt.wantProto {
+				t.Errorf("expected protocol %s, got %s", tt.wantProto, res.Proto)
+			}
+			// the server saw the same protocol
+			if !strings.Contains(string(body), "GET / "+tt.wantProto) {
+				t.Errorf("expected the echo of a %s request, got:\n%s", tt.wantProto, body)
+			}
+		})
+	}
+}
+
+func TestH2CUpgrade(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	srv := &http.Server{Handler: handler(newHealth(), newFaults())}
+	if err := configureHTTP2(srv, newConnTracker(), false, true); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	go srv.Serve(l) //nolint:errcheck
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	req.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	req.Header.Set("Upgrade", "h2c")
+	req.Header.Set("HTTP2-Settings", "AAMAAABkAARAAAAAAAIAAAAA")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Upgrade") != "h2c" {
+		t.Errorf("expected an upgrade to h2c, got %s %v", res.Status, res.Header)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	if _, err := newTransport("spdy", nil, nil); err == nil {
+		t.Errorf("expected an error for an invalid protocol, got none")
+	}
+}
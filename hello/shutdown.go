@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// connTracker follows the connections of an [http.Server] through its ConnState hook,
+// to know how many are drained on shutdown.
+//
+// The h2c connections are hijacked from the server, which does not wait for them on shutdown:
+// they are tracked apart until closed, see [configureHTTP2], and counted as active.
+// Their HTTP/2 server reports their states to the ConnState hook but not their closing,
+// so these reports are ignored.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+	h2c   map[net.Conn]bool
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		conns: map[net.Conn]http.ConnState{},
+		h2c:   map[net.Conn]bool{},
+	}
+}
+
+// trackH2C tracks a connection hijacked to serve HTTP/2 over cleartext, until untracked.
+// It is the connection given to the ConnState hook before.
+func (t *connTracker) trackH2C(c net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.h2c[c] = true
+}
+
+func (t *connTracker) untrackH2C(c net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.h2c, c)
+}
+
+// waitH2C waits for the h2c connections to be closed, until ctx is done
+func (t *connTracker) waitH2C(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		t.mu.Lock()
+		n := len(t.h2c)
+		t.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeH2C closes the h2c connections
+func (t *connTracker) closeH2C() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for c := range t.h2c {
+		_ = c.Close()
+	}
+}
+
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch state {
+	case http.StateNew:
+		t.conns[c] = state
+	case http.StateHijacked, http.StateClosed:
+		delete(t.conns, c)
+	default:
+		// not a connection hijacked to serve h2c
+		if _, ok := t.conns[c]; ok {
+			t.conns[c] = state
+		}
+	}
+}
+
+// count returns the number of idle connections, and of the others:
+// serving a request, not having sent one yet, or serving h2c.
+func (t *connTracker) count() (active, idle int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active = len(t.h2c)
+	for _, state := range t.conns {
+		if state == http.StateIdle {
+			idle++
+		} else {
+			active++
+		}
+	}
+	return active, idle
+}
+
+// Options for [shutdown], using functional options pattern.
+type shutdownOpts struct {
+	preStop time.Duration
+	timeout time.Duration
+}
+
+// withPreStop keeps serving requests for d before shutting down,
+// while readyz fails so that load balancers stop sending new ones.
+func withPreStop(d time.Duration) func(*shutdownOpts) {
+	return func(opts *shutdownOpts) {
+		opts.preStop = d
+	}
+}
+
+// withDrainTimeout closes the connections still active after d
+func withDrainTimeout(d time.Duration) func(*shutdownOpts) {
+	return func(opts *shutdownOpts) {
+		opts.timeout = d
+	}
+}
+
+// shutdown stops srv gracefully: readyz fails at once, then after the pre-stop delay
+// the server stops accepting connections and waits for the active ones to finish their request.
+// Those still active after the drain timeout are closed forcibly.
+//
+// It returns the number of connections drained and forcibly closed.
+func shutdown(srv *http.Server, conns *connTracker, hc *health, opts ...func(*shutdownOpts)) (drained, forced int, err error) {
+	options := &shutdownOpts{timeout: 30 * time.Second}
+	for _, o := range opts {
+		o(options)
+	}
+
+	hc.drain()
+	if options.preStop > 0 {
+		slog.Info("waiting before shutting down", "delay", options.preStop)
+		time.Sleep(options.preStop)
+	}
+
+	active, idle := conns.count()
+	slog.Info("shutting down", "active", active, "idle", idle, "timeout", options.timeout)
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), options.timeout)
+	defer cancel()
+	err = srv.Shutdown(ctx)
+	if err == nil {
+		err = conns.waitH2C(ctx)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		forced, _ = conns.count()
+		conns.closeH2C()
+		err = srv.Close()
+	}
+	drained = max(active-forced, 0)
+
+	slog.Info("shut down",
+		"drained", drained,
+		"forced", forced,
+		"idle", idle,
+		"duration", time.Since(start),
+	)
+	return drained, forced, err
+}
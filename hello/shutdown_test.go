@@ -0,0 +1,198 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name       string
+		h2c        bool
+		delay      time.Duration // of the in-flight request
+		opts       []func(*shutdownOpts)
+		wantErr    bool // of the in-flight request
+		wantDrain  int
+		wantForced int
+	}{
+		{
+			name:      "drained",
+			delay:     200 * time.Millisecond,
+			opts:      []func(*shutdownOpts){withDrainTimeout(5 * time.Second)},
+			wantDrain: 1,
+		},
+		{
+			name:       "forcibly closed",
+			delay:      5 * time.Second,
+			opts:       []func(*shutdownOpts){withDrainTimeout(200 * time.Millisecond)},
+			wantErr:    true,
+			wantForced: 1,
+		},
+		{
+			name:      "h2c drained",
+			h2c:       true,
+			delay:     200 * time.Millisecond,
+			opts:      []func(*shutdownOpts){withDrainTimeout(5 * time.Second)},
+			wantDrain: 1,
+		},
+		{
+			name:       "h2c forcibly closed",
+			h2c:        true,
+			delay:      5 * time.Second,
+			opts:       []func(*shutdownOpts){withDrainTimeout(200 * time.Millisecond)},
+			wantErr:    true,
+			wantForced: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := newHealth()
+			conns := newConnTracker()
+			started := make(chan struct{})
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+				}
+			})
+			srv := &http.Server{Handler: mux, ConnState: conns.track}
+			if err := configureHTTP2(srv, conns, false, tt.h2c); err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			proto := protoHTTP11
+			if tt.h2c {
+				proto = protoH2C
+			}
+			transport, err := newTransport(proto, nil, nil)
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			go srv.Serve(l) //nolint:errcheck
+
+			errc := make(chan error, 1)
+			go func() {
+				res, err := (&http.Client{Transport: transport}).Get("http://" + l.Addr().String())
+				if err == nil {
+					_, err = io.ReadAll(res.Body)
+					res.Body.Close()
+				}
+				errc <- err
+			}()
+			<-started
+
+			drained, forced, err := shutdown(srv, conns, hc, tt.opts...)
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if drained != tt.wantDrain {
+				t.Errorf("expected %d drained, got %d", tt.wantDrain, drained)
+			}
+			if forced != tt.wantForced {
+				t.Errorf("expected %d forced, got %d", tt.wantForced, forced)
+			}
+			var reqErr error
+			if tt.wantErr {
+				reqErr = <-errc
+			} else {
+				// a drained request is done before the server is shut down
+				select {
+				case reqErr = <-errc:
+				case <-time.After(50 * time.Millisecond):
+					t.Fatalf("expected the request to be done once shut down")
+				}
+			}
+			if (reqErr != nil) != tt.wantErr {
+				t.Errorf("expected request error %v, got %v", tt.wantErr, reqErr)
+			}
+		})
+	}
+}
+
+// TestPreStop checks that requests are served during the pre-stop delay, while readyz fails
+func TestPreStop(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	hc := newHealth()
+	conns := newConnTracker()
+	srv := &http.Server{Handler: handler(hc, newFaults()), ConnState: conns.track}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	go srv.Serve(l) //nolint:errcheck
+	url := "http://" + l.Addr().String()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, _, err := shutdown(srv, conns, hc, withPreStop(500*time.Millisecond)); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	for target, want := range map[string]int{
+		"/":       http.StatusOK,
+		"/readyz": http.StatusServiceUnavailable,
+		"/livez":  http.StatusOK,
+	} {
+		res, err := http.Get(url + target)
+		if err != nil {
+			t.Errorf("%s: expected no error during pre-stop delay, got %v", target, err)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Errorf("%s: expected status %d, got %d", target, want, res.StatusCode)
+		}
+	}
+
+	<-done
+	if _, err := http.Get(url); err == nil {
+		t.Errorf("expected an error once shut down, got none")
+	}
+}
+
+// TestConnTracker checks that an h2c connection is counted once, whatever its HTTP/2 server reports
+func TestConnTracker(t *testing.T) {
+	conns := newConnTracker()
+	c, other := net.Pipe()
+	defer c.Close()
+	defer other.Close()
+	// a wrapper of the connection, like the one given by h2c to the HTTP/2 server
+	wrapped := struct{ net.Conn }{c}
+
+	steps := []struct {
+		name       string
+		step       func()
+		wantActive int
+		wantIdle   int
+	}{
+		{name: "new", step: func() { conns.track(c, http.StateNew) }, wantActive: 1},
+		{name: "idle", step: func() { conns.track(c, http.StateIdle) }, wantIdle: 1},
+		{name: "hijacked", step: func() { conns.track(c, http.StateHijacked) }},
+		{name: "h2c", step: func() { conns.trackH2C(c) }, wantActive: 1},
+		{name: "reported by HTTP/2", step: func() { conns.track(c, http.StateActive) }, wantActive: 1},
+		{name: "wrapper reported by HTTP/2", step: func() { conns.track(wrapped, http.StateIdle) }, wantActive: 1},
+		{name: "h2c closed", step: func() { conns.untrackH2C(c) }},
+	}
+	for _, s := range steps {
+		s.step()
+		if active, idle := conns.count(); active != s.wantActive || idle != s.wantIdle {
+			t.Errorf("%s: expected %d active and %d idle, got %d and %d", s.name, s.wantActive, s.wantIdle, active, idle)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// toggleSignals are the signals toggling a probe, by probe name
+var toggleSignals = map[os.Signal]string{}
+
+// terminateSignals shut the server down gracefully, see [shutdown]
+var terminateSignals = []os.Signal{os.Interrupt}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// toggleSignals are the signals toggling a probe, by probe name
+var toggleSignals = map[os.Signal]string{
+	syscall.SIGUSR1: "readyz",
+	syscall.SIGUSR2: "livez",
+}
+
+// terminateSignals shut the server down gracefully, see [shutdown]
+var terminateSignals = []os.Signal{syscall.SIGTERM, os.Interrupt}
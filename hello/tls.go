@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultSANs are the names of the self-signed certificate when none is given
+var defaultSANs = []string{"localhost", "127.0.0.1", "::1"}
+
+// serverTLS is the TLS configuration of the server
+type serverTLS struct {
+	Cert, Key  string   // PEM files of the certificate and its key
+	SelfSigned bool     // generate a certificate instead of Cert and Key
+	SANs       []string // subject alternative names of the self-signed certificate
+	ClientCA   string   // PEM bundle verifying client certificates, which are then required (mTLS)
+}
+
+// config returns the configuration of the server, or nil to serve plain HTTP
+func (s serverTLS) config() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	switch {
+	case s.SelfSigned:
+		sans := s.SANs
+		if len(sans) == 0 {
+			sans = defaultSANs
+		}
+		certPEM, keyPEM, err := selfSigned(sans, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if cert, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(cert.Certificate[0])
+		slog.Info("generated a self-signed certificate", "sans", sans, "sha256", hex.EncodeToString(sum[:]))
+	case s.Cert != "" || s.Key != "":
+		if cert, err = tls.LoadX509KeyPair(s.Cert, s.Key); err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+	case s.ClientCA != "":
+		return nil, errors.New("client certificate verification requires a certificate (--tls-cert or --tls-self-signed)")
+	default:
+		return nil, nil
+	}
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if s.ClientCA != "" {
+		if cfg.ClientCAs, err = loadCertPool(s.ClientCA); err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// clientTLS is the TLS configuration of the client
+type clientTLS struct {
+	CACert    string // PEM bundle verifying the server certificate, instead of the system roots
+	Cert, Key string // PEM files of the client certificate and its key, for mTLS
+	Insecure  bool   // skip the verification of the server certificate
+	SNI       string // server name to send and verify, instead of the host of the URL
+}
+
+// config returns the configuration of the client, or nil for the default one
+func (c clientTLS) config() (*tls.Config, error) {
+	if c == (clientTLS{}) {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		ServerName:         c.SNI,
+		InsecureSkipVerify: c.Insecure, //nolint:gosec // on demand, for testing
+	}
+	var err error
+	if c.CACert != "" {
+		if cfg.RootCAs, err = loadCertPool(c.CACert); err != nil {
+			return nil, err
+		}
+	}
+	if c.Cert != "" || c.Key != "" {
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificate found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// selfSigned returns a PEM certificate valid for the names or IPs of sans, and its key.
+// It is valid for a year from now, for both server and client authentication.
+func selfSigned(sans []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"hello"}, CommonName: sans[0]},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, san)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// tlsDetails describes a TLS connection, as printed by [fetch]
+func tlsDetails(cs *tls.ConnectionState) []string {
+	lines := []string{
+		fmt.Sprintf("TLS connection using %s / %s", tls.VersionName(cs.Version), tls.CipherSuiteName(cs.CipherSuite)),
+	}
+	if cs.NegotiatedProtocol != "" {
+		lines = append(lines, "ALPN: server accepted "+cs.NegotiatedProtocol)
+	}
+	if cs.ServerName != "" {
+		lines = append(lines, "SNI: "+cs.ServerName)
+	}
+	if len(cs.PeerCertificates) > 0 {
+		cert := cs.PeerCertificates[0]
+		sans := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		lines = append(lines,
+			"Server certificate:",
+			" subject: "+cert.Subject.String(),
+			" issuer: "+cert.Issuer.String(),
+			" subjectAltNames: "+strings.Join(sans, ", "),
+			" expire date: "+cert.NotAfter.Format(time.RFC1123),
+		)
+	}
+	return lines
+}
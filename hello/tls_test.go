@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate and its key to dir, as name.pem and name.key
+func writeCert(t *testing.T, dir, name string, sans ...string) (certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM, err := selfSigned(sans, time.Now())
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	certFile, keyFile = filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLS(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	dir := t.TempDir()
+	serverCert, serverKey := writeCert(t, dir, "server", "localhost", "127.0.0.1")
+	clientCert, clientKey := writeCert(t, dir, "client", "client")
+
+	tests := []struct {
+		name     string
+		server   serverTLS
+		client   clientTLS
+		wantErr  string
+		wantBody string
+	}{
+		{
+			name:     "verified with CA bundle",
+			server:   serverTLS{Cert: serverCert, Key: serverKey},
+			client:   clientTLS{CACert: serverCert},
+			wantBody: "tls: TLS 1.3",
+		},
+		{
+			name:    "unknown authority",
+			server:  serverTLS{Cert: serverCert, Key: serverKey},
+			client:  clientTLS{SNI: "localhost"},
+			wantErr: "certificate signed by unknown authority",
+		},
+		{
+			name:   "insecure",
+			server: serverTLS{SelfSigned: true},
+			client: clientTLS{Insecure: true},
+		},
+		{
+			name:     "SNI override",
+			server:   serverTLS{Cert: serverCert, Key: serverKey},
+			client:   clientTLS{CACert: serverCert, SNI: "localhost"},
+			wantBody: `server name "localhost"`,
+		},
+		{
+			name:    "SNI override not matching",
+			server:  serverTLS{Cert: serverCert, Key: serverKey},
+			client:  clientTLS{CACert: serverCert, SNI: "example.com"},
+			wantErr: "not example.com",
+		},
+		{
+			name:    "mTLS without client certificate",
+			server:  serverTLS{Cert: serverCert, Key: serverKey, ClientCA: clientCert},
+			client:  clientTLS{CACert: serverCert},
+			wantErr: "certificate required",
+		},
+		{
+			name:     "mTLS",
+			server:   serverTLS{Cert: serverCert, Key: serverKey, ClientCA: clientCert},
+			client:   clientTLS{CACert: serverCert, Cert: clientCert, Key: clientKey},
+			wantBody: "tls client: CN=client,O=hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConfig, err := tt.server.config()
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			clientConfig, err := tt.client.config()
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			srv := &http.Server{
+				Handler:  handler(newHealth(), newFaults()),
+				ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+			}
+			go srv.Serve(tls.NewListener(l, serverConfig)) //nolint:errcheck
+			defer srv.Close()
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+			res, err := client.Get("https://" + l.Addr().String())
+			if err == nil {
+				var body []byte
+				body, err = io.ReadAll(res.Body)
+				res.Body.Close()
+				if !strings.Contains(string(body), tt.wantBody) {
+					t.Errorf("expected body to contain %q, got:\n%s", tt.wantBody, body)
+				}
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  serverTLS
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "plain HTTP", wantNil: true},
+		{name: "self-signed", server: serverTLS{SelfSigned: true}},
+		{name: "missing files", server: serverTLS{Cert: "missing.pem", Key: "missing.key"}, wantErr: true},
+		{name: "client CA without certificate", server: serverTLS{ClientCA: "ca.pem"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.server.config()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && (cfg == nil) != tt.wantNil {
+				t.Errorf("expected nil config %v, got %v", tt.wantNil, cfg)
+			}
+		})
+	}
+}
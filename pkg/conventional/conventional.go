@@ -0,0 +1,178 @@
+package conventional
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gforien/go/pkg/semver"
+)
+
+// Types are the commit types accepted by default.
+// See https://www.conventionalcommits.org
+var Types = []string{
+	"build", "chore", "ci", "docs", "feat", "fix",
+	"perf", "refactor", "revert", "style", "test",
+}
+
+// Commit is a commit message following the Conventional Commits format
+//
+//	type(scope)!: description
+//
+//	body
+//
+//	BREAKING CHANGE: footer
+type Commit struct {
+	Type        string
+	Scope       string
+	Description string
+	Body        string
+	Breaking    bool
+}
+
+var header = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^()\s]+)\))?(!)?: (\S.*)$`)
+
+// scissors is the line after which git ignores everything in a commit message
+const scissors = "# ------------------------ >8 ------------------------"
+
+// Parse parses a commit message.
+// Comment lines (starting with '#') are ignored, as git does by default.
+// If the message does not follow the format, ErrInvalidCommit is returned.
+func Parse(msg string) (Commit, error) {
+	lines := clean(msg)
+	if len(lines) == 0 {
+		return Commit{}, &ErrInvalidCommit{Message: msg, Reason: "empty commit message"}
+	}
+
+	matches := header.FindStringSubmatch(lines[0])
+	if matches == nil {
+		return Commit{}, &ErrInvalidCommit{
+			Message: msg,
+			Reason:  fmt.Sprintf("header %q does not match 'type(scope): description'", lines[0]),
+		}
+	}
+	if len(lines) > 1 && lines[1] != "" {
+		return Commit{}, &ErrInvalidCommit{Message: msg, Reason: "header must be followed by a blank line"}
+	}
+
+	c := Commit{
+		Type:        strings.ToLower(matches[1]),
+		Scope:       matches[2],
+		Description: matches[4],
+		Breaking:    matches[3] == "!",
+	}
+	if len(lines) > 2 {
+		c.Body = strings.Join(lines[2:], "\n")
+	}
+	for _, l := range lines[1:] {
+		if strings.HasPrefix(l, "BREAKING CHANGE:") || strings.HasPrefix(l, "BREAKING-CHANGE:") {
+			c.Breaking = true
+		}
+	}
+	return c, nil
+}
+
+// Validate parses a commit message and checks that its type is one of the given types.
+// If types is empty, [Types] is used.
+// Messages generated by git (merges, reverts, fixup! and squash! commits) are always valid.
+func Validate(msg string, types ...string) error {
+	lines := clean(msg)
+	if len(lines) > 0 && isGenerated(lines[0]) {
+		return nil
+	}
+
+	c, err := Parse(msg)
+	if err != nil {
+		return err
+	}
+
+	if len(types) == 0 {
+		types = Types
+	}
+	if !slices.Contains(types, c.Type) {
+		return &ErrInvalidCommit{
+			Message: msg,
+			Reason:  fmt.Sprintf("type %q is not one of %s", c.Type, strings.Join(types, ", ")),
+		}
+	}
+	return nil
+}
+
+// Bump returns the semver bump implied by the commit:
+// major for breaking changes, minor for features, patch for fixes.
+// Other commits return an empty Bump.
+func (c Commit) Bump() semver.Bump {
+	switch {
+	case c.Breaking:
+		return semver.Major
+	case c.Type == "feat":
+		return semver.Minor
+	case c.Type == "fix" || c.Type == "perf":
+		return semver.Patch
+	default:
+		return ""
+	}
+}
+
+// NextBump returns the highest bump implied by a list of commit messages.
+// Messages that are not conventional commits are ignored.
+// If no message implies a bump, an empty Bump is returned.
+func NextBump(msgs []string) semver.Bump {
+	var bump semver.Bump
+	for _, msg := range msgs {
+		c, err := Parse(msg)
+		if err != nil {
+			continue
+		}
+		switch b := c.Bump(); {
+		case b == semver.Major:
+			return semver.Major
+		case b == semver.Minor:
+			bump = semver.Minor
+		case b == semver.Patch && bump == "":
+			bump = semver.Patch
+		}
+	}
+	return bump
+}
+
+// clean removes comments, the scissors section and trailing blank lines
+func clean(msg string) []string {
+	var lines []string
+	for _, l := range strings.Split(msg, "\n") {
+		l = strings.TrimRight(l, " \t\r")
+		if l == scissors {
+			break
+		}
+		if strings.HasPrefix(l, "#") {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func isGenerated(header string) bool {
+	for _, prefix := range []string{"Merge ", "Revert ", "fixup! ", "squash! ", "amend! "} {
+		if strings.HasPrefix(header, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type ErrInvalidCommit struct {
+	Message string // the commit message that caused the error
+	Reason  string
+}
+
+func (e *ErrInvalidCommit) Error() string {
+	return "invalid conventional commit: " + e.Reason
+}
@@ -0,0 +1,119 @@
+package conventional
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gforien/go/pkg/semver"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name     string
+		given    string
+		expected Commit
+	}{
+		{
+			name:     "simple",
+			given:    "feat: add hooks",
+			expected: Commit{Type: "feat", Description: "add hooks"},
+		},
+		{
+			name:     "scope",
+			given:    "fix(semver): handle nil version\n",
+			expected: Commit{Type: "fix", Scope: "semver", Description: "handle nil version"},
+		},
+		{
+			name:     "breaking with bang",
+			given:    "refactor(git)!: drop prefix argument",
+			expected: Commit{Type: "refactor", Scope: "git", Description: "drop prefix argument", Breaking: true},
+		},
+		{
+			name:  "breaking footer",
+			given: "feat: new api\n\nsome body\n\nBREAKING CHANGE: old api removed",
+			expected: Commit{
+				Type:        "feat",
+				Description: "new api",
+				Body:        "some body\n\nBREAKING CHANGE: old api removed",
+				Breaking:    true,
+			},
+		},
+		{
+			name:     "comments and scissors",
+			given:    "# leading comment\nchore: tidy\n# Please enter the commit message\n" + scissors + "\ndiff --git a/x b/x",
+			expected: Commit{Type: "chore", Description: "tidy"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Parse(tc.given)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected %#v, got %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name        string
+		given       string
+		types       []string
+		expectError bool
+	}{
+		{name: "valid", given: "docs: update README"},
+		{name: "merge", given: "Merge branch 'main' into feature"},
+		{name: "fixup", given: "fixup! feat: add hooks"},
+		{name: "empty", given: "\n# only a comment\n", expectError: true},
+		{name: "no type", given: "add hooks", expectError: true},
+		{name: "missing space", given: "feat:add hooks", expectError: true},
+		{name: "unknown type", given: "wip: add hooks", expectError: true},
+		{name: "custom types", given: "wip: add hooks", types: []string{"wip"}},
+		{name: "no blank line", given: "feat: add hooks\nbody", expectError: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Validate(tc.given, tc.types...)
+			if tc.expectError {
+				var invalidErr *ErrInvalidCommit
+				if !errors.As(err, &invalidErr) {
+					t.Errorf("expected ErrInvalidCommit, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNextBump(t *testing.T) {
+	cases := []struct {
+		name     string
+		given    []string
+		expected semver.Bump
+	}{
+		{name: "none", given: []string{"docs: readme", "not conventional"}, expected: ""},
+		{name: "patch", given: []string{"fix: a", "chore: b"}, expected: semver.Patch},
+		{name: "minor", given: []string{"fix: a", "feat: b", "fix: c"}, expected: semver.Minor},
+		{name: "major", given: []string{"feat: a", "fix!: b"}, expected: semver.Major},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := NextBump(tc.given); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
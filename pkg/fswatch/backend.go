@@ -0,0 +1,54 @@
+package fswatch
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Source is a source of filesystem events, with the semantics of [fsnotify.Watcher]:
+// adding a directory watches its direct children.
+//
+// By default, a [Watcher] receives events from fsnotify, or by polling (see [WithPolling]).
+// Tests can give their own source with [WithSource], see package fswatchtest.
+type Source interface {
+	Add(name string) error
+	Remove(name string) error
+	Close() error
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+}
+
+// WithSource makes the watcher receive events from s instead of the OS.
+// The Events and Errors channels of s must be closed when s is closed.
+func WithSource(s Source) func(*Watcher) {
+	return func(w *Watcher) {
+		w.backend = s
+	}
+}
+
+// notifyBackend relies on the OS, through fsnotify (inotify on Linux)
+type notifyBackend struct {
+	w *fsnotify.Watcher
+}
+
+func newNotifyBackend() (*notifyBackend, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &notifyBackend{w}, nil
+}
+
+func (b *notifyBackend) Add(name string) error         { return b.w.Add(name) }
+func (b *notifyBackend) Remove(name string) error      { return b.w.Remove(name) }
+func (b *notifyBackend) Close() error                  { return b.w.Close() }
+func (b *notifyBackend) Events() <-chan fsnotify.Event { return b.w.Events }
+func (b *notifyBackend) Errors() <-chan error          { return b.w.Errors }
+
+// isWatchLimit returns true if err means that the OS cannot watch more paths,
+// e.g. when fs.inotify.max_user_watches is reached.
+func isWatchLimit(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE)
+}
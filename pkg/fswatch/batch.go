@@ -0,0 +1,128 @@
+package fswatch
+
+import (
+	"context"
+	"time"
+)
+
+// WatchBatch collects events until no event happened for the duration d,
+// then calls f once with the changes coalesced by a [Coalescer].
+// With [WithMaxWait], f is called after at most the given duration even if events keep coming.
+//
+// Calls to f are serialized: events happening while f runs are collected for the next call.
+// f is not called anymore once ctx is done, e.g. canceled by f itself to stop after a batch.
+// It returns like [Watcher.WatchContext], after the last call to f has returned.
+// When the watcher is closed, the pending changes are delivered before returning.
+func (w *Watcher) WatchBatch(ctx context.Context, d time.Duration, f func([]Change), opts ...(func(*WatchOpts))) error {
+	// apply options
+	options := &WatchOpts{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	sub := w.Subscribe(opts...)
+	defer sub.Unsubscribe()
+
+	var (
+		pending Coalescer
+		busy    bool // f is running
+		due     bool // the batch is ready but f is running
+		done    = make(chan struct{})
+	)
+
+	quiet := w.clock.NewTimer(d)
+	quiet.Stop()
+	defer quiet.Stop()
+	maxWait := w.clock.NewTimer(0)
+	maxWait.Stop()
+	defer maxWait.Stop()
+	waiting := false // maxWait is running
+
+	deliver := func() {
+		quiet.Stop()
+		maxWait.Stop()
+		waiting = false
+		if busy {
+			due = true
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		// events may cancel out, like a file created then removed
+		changes := pending.Flush()
+		if len(changes) == 0 {
+			return
+		}
+		busy = true
+		go func() {
+			f(changes)
+			done <- struct{}{}
+		}()
+	}
+
+	// wait returns once f is not running anymore
+	wait := func() {
+		if busy {
+			<-done
+			busy = false
+		}
+	}
+
+	for {
+		select {
+
+		case <-ctx.Done():
+			wait()
+			return ctx.Err()
+
+		case <-quiet.C():
+			deliver()
+
+		case <-maxWait.C():
+			deliver()
+
+		case <-done:
+			busy = false
+			if due {
+				due = false
+				deliver()
+			}
+
+		case event, ok := <-sub.Events:
+			if !ok {
+				wait()
+				if changes := pending.Flush(); len(changes) > 0 {
+					f(changes)
+				}
+				return nil
+			}
+
+			pending.Add(event.Event)
+			pending.addHashes(event)
+			quiet.Reset(d)
+			if options.maxWait > 0 && !waiting {
+				maxWait.Reset(options.maxWait)
+				waiting = true
+			}
+
+		case err, ok := <-sub.Errors:
+			if !ok {
+				wait()
+				return nil
+			}
+			if err = options.handleError(err); err != nil {
+				wait()
+				return err
+			}
+		}
+	}
+}
+
+// WithMaxWait sets the maximum duration [Watcher.WatchBatch] waits before calling its function,
+// even if events keep coming. By default, there is no maximum.
+func WithMaxWait(d time.Duration) func(*WatchOpts) {
+	return func(opts *WatchOpts) {
+		opts.maxWait = d
+	}
+}
@@ -0,0 +1,235 @@
+package fswatch
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestWatchBatch(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := make(chan []Change, 10)
+	go func() {
+		_ = w.WatchBatch(ctx, 50*time.Millisecond, func(changes []Change) {
+			batches <- changes
+		})
+	}()
+
+	// saving 200 files triggers one call
+	dir := t.TempDir()
+	for i := range 200 {
+		w.Events <- fsnotify.Event{Name: filepath.Join(dir, fmt.Sprintf("%d.go", i)), Op: fsnotify.Write}
+	}
+
+	select {
+	case changes := <-batches:
+		if len(changes) != 200 {
+			t.Errorf("expected 200 changes, got %d", len(changes))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for a batch")
+	}
+
+	select {
+	case changes := <-batches:
+		t.Errorf("expected a single batch, got another one: %v", changes)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatchBatchCancelled(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := make(chan []Change, 10)
+	go func() {
+		_ = w.WatchBatch(ctx, 50*time.Millisecond, func(changes []Change) {
+			batches <- changes
+		})
+	}()
+
+	// a temporary file created then removed changes nothing
+	dir := t.TempDir()
+	tmp := filepath.Join(dir, "4913")
+	w.Events <- fsnotify.Event{Name: tmp, Op: fsnotify.Create}
+	w.Events <- fsnotify.Event{Name: tmp, Op: fsnotify.Remove}
+
+	select {
+	case changes := <-batches:
+		t.Errorf("expected no batch, got %v", changes)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// the next batch is not affected
+	name := filepath.Join(dir, "main.go")
+	w.Events <- fsnotify.Event{Name: name, Op: fsnotify.Write}
+	select {
+	case changes := <-batches:
+		if len(changes) != 1 || changes[0].Path != name {
+			t.Errorf("expected a change of %s, got %v", name, changes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for a batch")
+	}
+}
+
+func TestWatchBatchMaxWait(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := make(chan []Change, 10)
+	go func() {
+		_ = w.WatchBatch(ctx, 100*time.Millisecond, func(changes []Change) {
+			batches <- changes
+		}, WithMaxWait(150*time.Millisecond))
+	}()
+
+	// events keep coming more often than the quiet period, for 500ms
+	dir := t.TempDir()
+	start := time.Now()
+	sent := make(chan struct{})
+	defer func() { <-sent }()
+	go func() {
+		defer close(sent)
+		for i := range 20 {
+			w.Events <- fsnotify.Event{Name: filepath.Join(dir, fmt.Sprintf("%d.go", i)), Op: fsnotify.Write}
+			time.Sleep(25 * time.Millisecond)
+		}
+	}()
+
+	select {
+	case <-batches:
+		if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+			t.Errorf("expected a batch after the max wait, got one after %v", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for a batch")
+	}
+}
+
+func TestWatchBatchSerialized(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	running := make(chan bool, 10)
+	release := make(chan struct{})
+	var calls [][]Change
+	go func() {
+		_ = w.WatchBatch(ctx, 10*time.Millisecond, func(changes []Change) {
+			running <- true
+			<-release
+			calls = append(calls, changes)
+			running <- false
+		})
+	}()
+
+	w.Events <- fsnotify.Event{Name: "a", Op: fsnotify.Write}
+	<-running
+
+	// events during the call are delivered in the next one
+	w.Events <- fsnotify.Event{Name: "b", Op: fsnotify.Write}
+	w.Events <- fsnotify.Event{Name: "c", Op: fsnotify.Write}
+	time.Sleep(50 * time.Millisecond)
+	release <- struct{}{}
+	<-running
+
+	<-running
+	release <- struct{}{}
+	<-running
+
+	expected := [][]Change{
+		{{Path: "a", Kind: Modified}},
+		{{Path: "b", Kind: Modified}, {Path: "c", Kind: Modified}},
+	}
+	if !slices.EqualFunc(calls, expected, slices.Equal) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+}
+
+// TestWatchBatchStop checks that f is not called again once it canceled the context,
+// even if the next batch was due while it ran
+func TestWatchBatchStop(t *testing.T) {
+	// WatchBatch may see that f returned before the context is done
+	for range 10 {
+		w, err := NewWatcher()
+		if err != nil {
+			t.Fatalf("error in test setup: creating watcher: %v", err)
+		}
+		defer w.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		dir := t.TempDir()
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+		handling := make(chan struct{})
+		handled := make(chan struct{})
+		calls := 0
+		errc := make(chan error, 1)
+		go func() {
+			errc <- w.WatchBatch(ctx, 10*time.Millisecond, func(changes []Change) {
+				calls++
+				started <- struct{}{}
+				<-release
+				cancel()
+			}, WithErrorHandler(func(error) error {
+				// keeps WatchBatch busy while f returns
+				close(handling)
+				<-handled
+				return nil
+			}))
+		}()
+
+		w.Events <- fsnotify.Event{Name: filepath.Join(dir, "a.go"), Op: fsnotify.Write}
+		<-started
+		// the next batch is due while f runs
+		w.Events <- fsnotify.Event{Name: filepath.Join(dir, "b.go"), Op: fsnotify.Write}
+		time.Sleep(50 * time.Millisecond)
+		w.Errors <- fmt.Errorf("busy")
+		<-handling
+		close(release)
+		time.Sleep(10 * time.Millisecond)
+		close(handled)
+
+		select {
+		case <-errc:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for WatchBatch to return")
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 call, got %d", calls)
+		}
+	}
+}
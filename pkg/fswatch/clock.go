@@ -0,0 +1,49 @@
+package fswatch
+
+import "time"
+
+// Clock is the source of time of a [Watcher], for debouncing and batching.
+// Tests can give their own clock with [WithClock], see package fswatchtest.
+//
+// Note that polling (see [WithPolling]) always uses the real time.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a timer sending the time on its channel after d
+	NewTimer(d time.Duration) Timer
+	// AfterFunc returns a timer calling f in its own goroutine after d
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a [time.Timer] created by a [Clock]
+type Timer interface {
+	// C returns the channel of the timer, nil for the timers of [Clock.AfterFunc]
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// WithClock makes the watcher use c instead of the real time
+func WithClock(c Clock) func(*Watcher) {
+	return func(w *Watcher) {
+		w.clock = c
+	}
+}
+
+// realClock is the real time, of the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
@@ -0,0 +1,187 @@
+package fswatch
+
+import (
+	"github.com/fsnotify/fsnotify"
+)
+
+// ChangeKind is the kind of a [Change]
+type ChangeKind int
+
+const (
+	Created ChangeKind = iota + 1
+	Modified
+	Deleted
+	Renamed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	case Renamed:
+		return "renamed"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a logical change of a path, coalesced from one or more events.
+type Change struct {
+	Path string
+	Kind ChangeKind
+	From string // the previous path, for Renamed changes
+
+	// the hashes of the content before and after a Modified change, see [WithContentHash]
+	OldHash string
+	NewHash string
+}
+
+func (c Change) String() string {
+	if c.Kind == Renamed {
+		return c.Kind.String() + " " + c.From + " -> " + c.Path
+	}
+	return c.Kind.String() + " " + c.Path
+}
+
+// Coalescer normalizes a sequence of events into one logical change per path.
+//
+//   - Create, Write and Chmod of a new path is one Created change.
+//   - Any sequence of events on a path which exists before and after is one Modified change,
+//     including Remove or Rename followed by Create, as done by editors saving a file.
+//   - A path created then removed or renamed away is no change at all, as with temporary files.
+//   - A Rename followed by a Create is a rename from the first path to the second,
+//     as reported by fsnotify. It is one Renamed change, unless the renamed path was created
+//     within the sequence: writing a temporary file then renaming it over the target
+//     (atomic save) is one Modified change of the target.
+//     Note that this is also the case if the target did not exist before.
+//   - A Rename without a following Create moved the path out of the watched tree:
+//     it is one Deleted change.
+//
+// Changes are returned in the order their paths were first seen.
+// The zero value is ready to use.
+type Coalescer struct {
+	paths   []string
+	states  map[string]*pathState
+	renamed string // path of the last event if it was a Rename
+}
+
+type pathState struct {
+	existed bool   // the path existed before the first event
+	exists  bool   // the path exists after the last event
+	from    string // the path this one was renamed from
+	movedTo string // the path this one was renamed to
+
+	hashed  bool // the hashes are known
+	oldHash string
+	newHash string
+}
+
+// Coalesce returns the changes for a sequence of events. See [Coalescer].
+func Coalesce(events []fsnotify.Event) []Change {
+	var c Coalescer
+	for _, e := range events {
+		c.Add(e)
+	}
+	return c.Flush()
+}
+
+// Add adds an event to the sequence.
+func (c *Coalescer) Add(e fsnotify.Event) {
+	if c.states == nil {
+		c.states = map[string]*pathState{}
+	}
+	renamed := c.renamed
+	c.renamed = ""
+
+	s, seen := c.states[e.Name]
+	if !seen {
+		// the first event tells if the path existed before
+		s = &pathState{existed: !e.Has(fsnotify.Create), exists: !e.Has(fsnotify.Create)}
+		c.states[e.Name] = s
+		c.paths = append(c.paths, e.Name)
+	}
+
+	switch {
+	case e.Has(fsnotify.Remove):
+		s.exists = false
+		s.movedTo = ""
+
+	case e.Has(fsnotify.Rename):
+		s.exists = false
+		s.movedTo = ""
+		c.renamed = e.Name
+
+	case e.Has(fsnotify.Create):
+		if s.exists {
+			// duplicate event
+			return
+		}
+		s.exists = true
+		s.from = ""
+
+		from, ok := c.states[renamed]
+		if !ok || renamed == e.Name {
+			return
+		}
+		from.movedTo = e.Name
+		switch {
+		case from.from != "":
+			// renamed twice
+			s.from = from.from
+		case from.existed:
+			s.from = renamed
+		default:
+			// atomic save: the target is replaced by a temporary file
+			s.existed = s.existed || !seen
+		}
+
+	default:
+		// Write and Chmod
+		s.exists = true
+	}
+}
+
+// addHashes records the hashes of an event added with [Coalescer.Add]
+func (c *Coalescer) addHashes(e Event) {
+	s, ok := c.states[e.Name]
+	if !ok || e.NewHash == "" {
+		return
+	}
+	if !s.hashed {
+		s.hashed = true
+		s.oldHash = e.OldHash
+	}
+	s.newHash = e.NewHash
+}
+
+// Len returns the number of paths with pending events.
+func (c *Coalescer) Len() int {
+	return len(c.states)
+}
+
+// Flush returns the changes for the events added so far, and resets the sequence.
+func (c *Coalescer) Flush() []Change {
+	changes := make([]Change, 0, len(c.paths))
+	for _, path := range c.paths {
+		s := c.states[path]
+		switch {
+		case s.existed && s.exists:
+			changes = append(changes, Change{Path: path, Kind: Modified, OldHash: s.oldHash, NewHash: s.newHash})
+		case s.existed && !s.exists && s.movedTo == "":
+			changes = append(changes, Change{Path: path, Kind: Deleted})
+		case !s.existed && s.exists && s.from != "":
+			changes = append(changes, Change{Path: path, Kind: Renamed, From: s.from})
+		case !s.existed && s.exists:
+			changes = append(changes, Change{Path: path, Kind: Created})
+		}
+	}
+
+	c.paths = nil
+	c.states = nil
+	c.renamed = ""
+	return changes
+}
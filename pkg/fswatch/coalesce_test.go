@@ -0,0 +1,167 @@
+package fswatch
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ev is a shorthand for recorded events
+func ev(op fsnotify.Op, name string) fsnotify.Event {
+	return fsnotify.Event{Name: name, Op: op}
+}
+
+func TestCoalesce(t *testing.T) {
+	const (
+		create = fsnotify.Create
+		write  = fsnotify.Write
+		remove = fsnotify.Remove
+		rename = fsnotify.Rename
+		chmod  = fsnotify.Chmod
+	)
+
+	cases := []struct {
+		name     string
+		given    []fsnotify.Event
+		expected []Change
+	}{
+		{
+			name:     "create write chmod",
+			given:    []fsnotify.Event{ev(create, "a"), ev(write, "a"), ev(chmod, "a")},
+			expected: []Change{{Path: "a", Kind: Created}},
+		},
+		{
+			name:     "writes",
+			given:    []fsnotify.Event{ev(write, "a"), ev(write, "a"), ev(chmod, "a")},
+			expected: []Change{{Path: "a", Kind: Modified}},
+		},
+		{
+			name:     "remove then create",
+			given:    []fsnotify.Event{ev(remove, "a"), ev(create, "a"), ev(write, "a")},
+			expected: []Change{{Path: "a", Kind: Modified}},
+		},
+		{
+			name:     "create then remove",
+			given:    []fsnotify.Event{ev(create, "a"), ev(write, "b"), ev(remove, "a"), ev(remove, "c")},
+			expected: []Change{{Path: "b", Kind: Modified}, {Path: "c", Kind: Deleted}},
+		},
+		{
+			name:     "rename",
+			given:    []fsnotify.Event{ev(rename, "a"), ev(create, "b")},
+			expected: []Change{{Path: "b", Kind: Renamed, From: "a"}},
+		},
+		{
+			name:     "renamed twice",
+			given:    []fsnotify.Event{ev(rename, "a"), ev(create, "b"), ev(rename, "b"), ev(create, "c")},
+			expected: []Change{{Path: "c", Kind: Renamed, From: "a"}},
+		},
+		{
+			name:     "moved out of the tree",
+			given:    []fsnotify.Event{ev(rename, "a"), ev(write, "b")},
+			expected: []Change{{Path: "a", Kind: Deleted}, {Path: "b", Kind: Modified}},
+		},
+		{
+			name:     "moved into the tree",
+			given:    []fsnotify.Event{ev(create, "a")},
+			expected: []Change{{Path: "a", Kind: Created}},
+		},
+		{
+			// :w in vim, with the default 'writebackup'
+			name: "vim",
+			given: []fsnotify.Event{
+				ev(create, "4913"),
+				ev(chmod, "4913"),
+				ev(remove, "4913"),
+				ev(rename, "main.go"),
+				ev(create, "main.go~"),
+				ev(create, "main.go"),
+				ev(write, "main.go"),
+				ev(chmod, "main.go"),
+				ev(remove, "main.go~"),
+			},
+			expected: []Change{{Path: "main.go", Kind: Modified}},
+		},
+		{
+			// write a temporary file then rename it over the target
+			name: "atomic save",
+			given: []fsnotify.Event{
+				ev(create, ".main.go.tmp.1234"),
+				ev(write, ".main.go.tmp.1234"),
+				ev(chmod, ".main.go.tmp.1234"),
+				ev(rename, ".main.go.tmp.1234"),
+				ev(create, "main.go"),
+			},
+			expected: []Change{{Path: "main.go", Kind: Modified}},
+		},
+		{
+			// "safe write" of JetBrains IDEs: the target is moved away before being replaced
+			name: "safe write",
+			given: []fsnotify.Event{
+				ev(create, "main.go___jb_tmp___"),
+				ev(write, "main.go___jb_tmp___"),
+				ev(rename, "main.go"),
+				ev(create, "main.go___jb_old___"),
+				ev(rename, "main.go___jb_tmp___"),
+				ev(create, "main.go"),
+				ev(remove, "main.go___jb_old___"),
+			},
+			expected: []Change{{Path: "main.go", Kind: Modified}},
+		},
+		{
+			name: "git checkout",
+			given: []fsnotify.Event{
+				ev(create, ".git/index.lock"),
+				ev(write, ".git/index.lock"),
+				ev(remove, "old.go"),
+				ev(create, "new.go"),
+				ev(write, "new.go"),
+				ev(write, "main.go"),
+				ev(create, "pkg"),
+				ev(create, "pkg/x.go"),
+				ev(write, "pkg/x.go"),
+				ev(create, "pkg/x.go"), // synthetic event from the watcher
+				ev(rename, ".git/index.lock"),
+				ev(create, ".git/index"),
+				ev(chmod, ".git/index"),
+			},
+			expected: []Change{
+				{Path: "old.go", Kind: Deleted},
+				{Path: "new.go", Kind: Created},
+				{Path: "main.go", Kind: Modified},
+				{Path: "pkg", Kind: Created},
+				{Path: "pkg/x.go", Kind: Created},
+				{Path: ".git/index", Kind: Modified},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := Coalesce(tc.given); !slices.Equal(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCoalescerFlush(t *testing.T) {
+	var c Coalescer
+	c.Add(ev(fsnotify.Create, "a"))
+	if c.Len() != 1 {
+		t.Errorf("expected 1 pending path, got %d", c.Len())
+	}
+	c.Flush()
+	if c.Len() != 0 {
+		t.Errorf("expected no pending path after flush, got %d", c.Len())
+	}
+
+	// the path existed when the next window starts
+	c.Add(ev(fsnotify.Write, "a"))
+	expected := []Change{{Path: "a", Kind: Modified}}
+	if got := c.Flush(); !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
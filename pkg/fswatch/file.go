@@ -0,0 +1,266 @@
+package fswatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// AddFile watches a single file through its parent directory,
+// so that the watch survives the file being replaced by a rename (atomic save)
+// or removed and created again (log rotation). The file does not need to exist.
+//
+// Only the events of the files added this way are delivered for the directory,
+// unless the directory itself is watched with [Watcher.Add] or [Watcher.AddRecursive].
+func (w *Watcher) AddFile(name string) error {
+	name = filepath.Clean(name)
+	dir := filepath.Dir(name)
+
+	w.mu.Lock()
+	dirWatched := w.watched[dir] && !w.fileDirs[dir]
+	w.mu.Unlock()
+	if !dirWatched {
+		if err := w.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	if !dirWatched {
+		w.fileDirs[dir] = true
+	}
+	w.files[name]++
+	w.mu.Unlock()
+
+	if !w.seeds() {
+		return nil
+	}
+	if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+		w.seed(map[string]fs.FileInfo{name: info})
+	}
+	return nil
+}
+
+// RemoveFile stops watching a file added with [Watcher.AddFile].
+// The file is watched until it is removed as many times as it was added.
+func (w *Watcher) RemoveFile(name string) error {
+	name = filepath.Clean(name)
+	dir := filepath.Dir(name)
+
+	w.mu.Lock()
+	if w.files[name] == 0 {
+		w.mu.Unlock()
+		return fsnotify.ErrNonExistentWatch
+	}
+	w.files[name]--
+	if w.files[name] > 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	delete(w.files, name)
+
+	for file := range w.files {
+		if filepath.Dir(file) == dir {
+			// still needed for another file
+			w.mu.Unlock()
+			return nil
+		}
+	}
+	fileDir := w.fileDirs[dir]
+	delete(w.fileDirs, dir)
+	w.mu.Unlock()
+
+	if !fileDir {
+		return nil
+	}
+	return w.Remove(dir)
+}
+
+// isOtherFile returns true if path is in a directory only watched for some of its files,
+// and is not one of them
+func (w *Watcher) isOtherFile(path string) bool {
+	path = filepath.Clean(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.fileDirs[filepath.Dir(path)] && w.files[path] == 0
+}
+
+// Tail calls f with every line appended to the file at path, like tail -F,
+// until the context is done or the watcher is closed. It returns like [Watcher.WatchContext].
+//
+// The file is watched with [Watcher.AddFile]: when it is renamed or removed, the rest of it is read,
+// then the new file at path is read from its start (log rotation).
+// When it is truncated, it is read again from its start (copytruncate), provided that
+// the truncation is noticed before the file grows back to the size already read.
+// The file does not need to exist, and lines are read from its end unless [WithFromStart] is given.
+//
+// Lines are given without their line ending. An unterminated last line is given
+// once terminated, or when the file is rotated.
+func (w *Watcher) Tail(ctx context.Context, path string, f func(line string), opts ...(func(*WatchOpts))) error {
+	// apply options
+	options := &WatchOpts{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	if err := w.AddFile(path); err != nil {
+		return err
+	}
+	defer w.RemoveFile(path)
+
+	// subscribe before opening, so that no write is missed
+	sub := w.Subscribe(append(slices.Clone(opts), WithPrefix(path))...)
+	defer sub.Unsubscribe()
+
+	t := &tail{path: path, f: f}
+	defer t.close()
+	err := t.open(!options.fromStart)
+	if err == nil {
+		err = t.read()
+	}
+	if err = options.handleError(err); err != nil {
+		return err
+	}
+
+	for {
+		select {
+
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-sub.Events:
+			if !ok {
+				return nil
+			}
+			if err = options.handleError(t.handle(event.Event)); err != nil {
+				return err
+			}
+
+		case err, ok := <-sub.Errors:
+			if !ok {
+				return nil
+			}
+			if err = options.handleError(err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// WithFromStart makes [Watcher.Tail] read the file from its start instead of its end
+func WithFromStart() func(*WatchOpts) {
+	return func(opts *WatchOpts) {
+		opts.fromStart = true
+	}
+}
+
+// tail reads the lines appended to a file
+type tail struct {
+	path    string
+	f       func(line string)
+	file    *os.File
+	offset  int64
+	partial []byte // the unterminated last line
+}
+
+// open opens the file at path, at its end or start.
+// The file not existing is not an error.
+func (t *tail) open(atEnd bool) error {
+	file, err := os.Open(t.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	t.file, t.offset = file, 0
+	if atEnd {
+		if t.offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			t.close()
+			return err
+		}
+	}
+	return nil
+}
+
+// close closes the file, giving the unterminated last line
+func (t *tail) close() {
+	if t.file == nil {
+		return
+	}
+	t.file.Close()
+	t.file = nil
+	if len(t.partial) > 0 {
+		t.f(string(t.partial))
+		t.partial = nil
+	}
+}
+
+// handle reads the file according to an event on its path
+func (t *tail) handle(e fsnotify.Event) error {
+	switch {
+	case e.Has(fsnotify.Create):
+		// replaced: finish the previous file
+		err := t.read()
+		t.close()
+		if err := t.open(false); err != nil {
+			return err
+		}
+		return errors.Join(err, t.read())
+
+	case e.Has(fsnotify.Remove) || e.Has(fsnotify.Rename):
+		// moved away: finish it, and wait for the new one
+		err := t.read()
+		t.close()
+		return err
+
+	default:
+		if t.file == nil {
+			// created while not watched yet
+			if err := t.open(false); err != nil {
+				return err
+			}
+		}
+		return t.read()
+	}
+}
+
+// read gives the complete lines appended since the last read
+func (t *tail) read() error {
+	if t.file == nil {
+		return nil
+	}
+
+	info, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < t.offset {
+		// truncated
+		t.offset = 0
+		t.partial = nil
+	}
+
+	b, err := io.ReadAll(io.NewSectionReader(t.file, t.offset, info.Size()-t.offset))
+	t.offset += int64(len(b))
+	data := append(t.partial, b...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		t.f(string(bytes.TrimSuffix(data[:i], []byte("\r"))))
+		data = data[i+1:]
+	}
+	t.partial = slices.Clone(data)
+	return err
+}
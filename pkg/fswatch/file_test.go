@@ -0,0 +1,127 @@
+package fswatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestAddFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFiles(t, dir, map[string]string{"config.yaml": "a", "other.txt": ""})
+
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.AddFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sub := w.Subscribe()
+
+	// other files of the directory are not watched
+	writeFiles(t, dir, map[string]string{"other.txt": "b"})
+	// atomic save, twice
+	for _, content := range []string{"b", "c"} {
+		writeFiles(t, dir, map[string]string{"config.yaml.tmp": content})
+		rename(t, path+".tmp", path)
+	}
+
+	var creates int
+	for creates < 2 {
+		e := receive(t, sub)
+		if e.Name != path {
+			t.Fatalf("unexpected event %v", e)
+		}
+		if e.Has(fsnotify.Create) {
+			creates++
+		}
+	}
+
+	if err := w.RemoveFile(path); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if watched := w.Watched(); len(watched) != 0 {
+		t.Errorf("expected nothing watched, got %v", watched)
+	}
+}
+
+func TestTail(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFiles(t, dir, map[string]string{"app.log": "old\n"})
+
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string, 100)
+	go func() {
+		_ = w.Tail(ctx, path, func(line string) {
+			lines <- line
+		})
+	}()
+	waitFor(t, "the file to be watched", func() bool {
+		return slices.Contains(w.Watched(), dir)
+	})
+	// let Tail open the file
+	time.Sleep(50 * time.Millisecond)
+
+	expect := func(t *testing.T, expected ...string) {
+		t.Helper()
+		var got []string
+		for len(got) < len(expected) {
+			select {
+			case line := <-lines:
+				got = append(got, line)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected lines %q, got %q", expected, got)
+			}
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("expected lines %q, got %q", expected, got)
+		}
+	}
+
+	appendFile(t, path, "one\ntw")
+	appendFile(t, path, "o\r\n")
+	expect(t, "one", "two")
+
+	// rotation: the rest of the old file is read, then the new file from its start
+	appendFile(t, path, "three\nunterminated")
+	rename(t, path, path+".1")
+	appendFile(t, path, "four\n")
+	expect(t, "three", "unterminated", "four")
+
+	// copytruncate, noticed before the next write as the file is shorter
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "five\n")
+	expect(t, "five")
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+}
@@ -1,9 +1,16 @@
 package fswatch
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,130 +18,667 @@ import (
 )
 
 // Note that [Watcher] is not an interface
+//
+// Like [fsnotify.Watcher], events and errors are sent on the Events and Errors channels,
+// which are closed once the watcher is closed.
+// Events are either received from the OS through fsnotify, or by polling (see [WithPolling]).
+// Reading the channels from several goroutines splits the events between them:
+// use [Watcher.Subscribe] instead, as the Watch methods do.
 type Watcher struct {
-	*fsnotify.Watcher
+	Events chan fsnotify.Event
+	Errors chan error
+
+	backend  Source
+	fallback Source // polling backend for the paths the main backend cannot watch
+	wg       sync.WaitGroup
+	done     chan struct{}
+	once     sync.Once
+
+	clock        Clock
+	ignore       *Ignore
+	symlinks     SymlinkPolicy
+	polling      pollMode
+	pollInterval time.Duration
+	pollHash     bool
+	rescanRoots  bool // keep snapshots of the roots, see WithRescan
+	maxHashSize  int64
+	hashOnAdd    bool
+
+	hashMu  sync.Mutex
+	hashes  map[string]contentHash // by path, with WithContentHash
+	held    map[string]*heldEvent  // by path, the events waiting to be hashed
+	seeding sync.WaitGroup         // hashing the files found when adding paths
+
+	mu        sync.Mutex
+	roots     []string             // roots added with AddRecursive
+	watched   map[string]bool      // paths currently watched
+	snapshots map[string]*Snapshot // state of the roots, with WithRescan
+	files     map[string]int       // files added with AddFile, with the number of times
+	links     map[string][]string  // links followed by real path of their target, see SymlinkFollow
+	fileDirs  map[string]bool      // directories watched only for their files
+
+	subsMu       sync.Mutex
+	subsCond     *sync.Cond // signaled when subscribing and closing
+	subs         map[*Subscription]bool
+	dispatchOnce sync.Once
+	dispatched   bool // the watcher is closed and the subscriptions too
+}
+
+type pollMode int
+
+const (
+	pollNever pollMode = iota
+	pollAlways
+	pollFallback
+)
+
+// DefaultPollInterval is the interval of polling when none is given
+const DefaultPollInterval = time.Second
+
+func NewWatcher(opts ...func(*Watcher)) (*Watcher, error) {
+	w := &Watcher{
+		Events:    make(chan fsnotify.Event),
+		Errors:    make(chan error),
+		done:      make(chan struct{}),
+		watched:   map[string]bool{},
+		snapshots: map[string]*Snapshot{},
+		files:     map[string]int{},
+		links:     map[string][]string{},
+		fileDirs:  map[string]bool{},
+		subs:      map[*Subscription]bool{},
+	}
+	for _, o := range opts {
+		o(w)
+	}
+	if w.pollInterval <= 0 {
+		w.pollInterval = DefaultPollInterval
+	}
+	if w.clock == nil {
+		w.clock = realClock{}
+	}
+	w.subsCond = sync.NewCond(&w.subsMu)
+
+	switch {
+	case w.backend != nil:
+		// given with WithSource
+	case w.polling == pollAlways:
+		w.backend = newPoller(w.pollInterval, w.pollHash)
+	default:
+		b, err := newNotifyBackend()
+		if isWatchLimit(err) && w.polling == pollFallback {
+			// no more inotify instances: poll everything
+			w.backend = newPoller(w.pollInterval, w.pollHash)
+			break
+		}
+		if isWatchLimit(err) {
+			return nil, newInstanceLimitError(err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		w.backend = b
+	}
+	if w.polling == pollFallback && w.fallback == nil {
+		w.fallback = newPoller(w.pollInterval, w.pollHash)
+	}
+
+	w.wg.Add(1)
+	go w.forward(w.backend)
+	if w.fallback != nil {
+		w.wg.Add(1)
+		go w.forward(w.fallback)
+	}
+	go func() {
+		w.wg.Wait()
+		close(w.Events)
+		close(w.Errors)
+	}()
+
+	return w, nil
+}
+
+// forward sends the events and errors of a backend to the watcher channels.
+// When the OS dropped events, the roots are rescanned instead (see [WithRescan]).
+func (w *Watcher) forward(b Source) {
+	defer w.wg.Done()
+
+	events, errs := b.Events(), b.Errors()
+	for events != nil || errs != nil {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			select {
+			case w.Events <- e:
+			case <-w.done:
+				return
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) && w.rescanRoots {
+				if !w.sendRescan() {
+					return
+				}
+				continue
+			}
+			select {
+			case w.Errors <- err:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// WithIgnore makes the watcher skip the directories matched by ig in [Watcher.AddRecursive],
+// and drop the events for the paths matched by ig.
+func WithIgnore(ig *Ignore) func(*Watcher) {
+	return func(w *Watcher) {
+		w.ignore = ig
+	}
+}
+
+// WithPolling makes the watcher scan the watched paths at the given interval,
+// instead of receiving events from the OS.
+// Use it for filesystems where fsnotify receives nothing: NFS, SMB, some Docker bind mounts, FUSE.
+func WithPolling(interval time.Duration) func(*Watcher) {
+	return func(w *Watcher) {
+		w.polling = pollAlways
+		w.pollInterval = interval
+	}
+}
+
+// WithPollingFallback makes the watcher receive events from the OS,
+// but poll at the given interval the paths it cannot watch because the OS limit is reached
+// (e.g. fs.inotify.max_user_watches).
+func WithPollingFallback(interval time.Duration) func(*Watcher) {
+	return func(w *Watcher) {
+		w.polling = pollFallback
+		w.pollInterval = interval
+	}
 }
 
-func NewWatcher() (*Watcher, error) {
-	watcher, err := fsnotify.NewWatcher()
+// WithPollHash makes polling compare the content of files, in addition to
+// their identity, size, modification time and mode.
+// This detects changes which preserve the size and modification time, at the cost of reading every file.
+func WithPollHash() func(*Watcher) {
+	return func(w *Watcher) {
+		w.pollHash = true
+	}
+}
+
+// WithRescan keeps a [Snapshot] of the roots added with [Watcher.AddRecursive], up to date with the events:
+// if the OS drops events ([fsnotify.ErrEventOverflow]), the roots are rescanned and synthetic events
+// derived from the changes are sent instead of the error.
+// Taking a snapshot walks the whole tree, and reads every file with [WithPollHash].
+func WithRescan() func(*Watcher) {
+	return func(w *Watcher) {
+		w.rescanRoots = true
+	}
+}
+
+// Same as [fsnotify.Watcher.Add] but keeps track of the watched paths.
+//
+// If the OS cannot watch more paths, a [WatchLimitError] is returned,
+// unless the watcher falls back to polling (see [WithPollingFallback]).
+func (w *Watcher) Add(name string) error {
+	err := w.backend.Add(name)
+	if err != nil && w.fallback != nil && isWatchLimit(err) {
+		err = w.fallback.Add(name)
+	}
+	if isWatchLimit(err) {
+		w.mu.Lock()
+		held := len(w.watched)
+		w.mu.Unlock()
+		return newWatchLimitError(err, held)
+	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+	w.mu.Lock()
+	w.watched[filepath.Clean(name)] = true
+	delete(w.fileDirs, filepath.Clean(name))
+	w.mu.Unlock()
+	return nil
+}
+
+// Same as [fsnotify.Watcher.Remove] but keeps track of the watched paths.
+func (w *Watcher) Remove(name string) error {
+	w.mu.Lock()
+	delete(w.watched, filepath.Clean(name))
+	w.mu.Unlock()
+	return w.remove(name)
+}
+
+func (w *Watcher) remove(name string) error {
+	err := w.backend.Remove(name)
+	if w.fallback != nil && errors.Is(err, fsnotify.ErrNonExistentWatch) {
+		err = w.fallback.Remove(name)
 	}
-	return &Watcher{watcher}, nil
+	return err
+}
+
+// Close stops watching and closes the Events and Errors channels.
+func (w *Watcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	w.subsMu.Lock()
+	w.subsCond.Broadcast()
+	w.subsMu.Unlock()
+
+	err := w.backend.Close()
+	if w.fallback != nil {
+		err = errors.Join(err, w.fallback.Close())
+	}
+	return err
+}
+
+// Watched returns the sorted list of paths currently watched.
+func (w *Watcher) Watched() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	paths := make([]string, 0, len(w.watched))
+	for path := range w.watched {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
 }
 
 // Same as [fsnotify.Watcher.Add] but adds subdirectories recursively.
+// Directories created later under root are watched as well,
+// and directories removed or renamed are not watched anymore.
+// Ignored directories (see [WithIgnore]) are skipped, except root itself.
+// Symbolic links are handled according to [WithSymlinks].
+// With [WithRescan], the tree is rescanned if the OS drops events.
+//
+// If the OS cannot watch more paths, the returned [AddError] wraps a [WatchLimitError]
+// which reports how many directories of the tree remain unwatched.
 func (w *Watcher) AddRecursive(root string) error {
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	if w.rescanRoots {
+		snapshot, err := TakeSnapshot(root, w.snapshotOpts()...)
 		if err != nil {
-			return err
+			return &AddError{Path: root, Err: err}
+		}
+		w.mu.Lock()
+		w.snapshots[snapshot.Root] = snapshot
+		w.mu.Unlock()
+	}
+	w.mu.Lock()
+	w.roots = append(w.roots, filepath.Clean(root))
+	w.mu.Unlock()
+
+	a := w.newTreeAdder()
+	err := a.walk(root)
+	w.seed(a.files)
+	if err != nil {
+		return err
+	}
+	return a.err()
+}
+
+// isRecursive returns true if path is under a root added with AddRecursive
+func (w *Watcher) isRecursive(path string) bool {
+	w.mu.Lock()
+	for _, root := range w.roots {
+		if isUnder(path, root) {
+			w.mu.Unlock()
+			return true
+		}
+	}
+	w.mu.Unlock()
+
+	// the directories reached through links
+	return w.isLinked(path)
+}
+
+// isUnder returns true if path is dir or is inside dir
+func isUnder(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// process updates the watched directories according to an event,
+// and returns the events to deliver.
+// The returned error is an [AddError] if a new directory could not be watched.
+//
+// When a directory is created under a recursive root, it is watched with its subdirectories,
+// and a synthetic Create event is returned for everything already inside it,
+// since these were created before the watch was established (e.g. mkdir -p, git checkout).
+// When a watched directory is removed or renamed, it is not watched anymore with its subdirectories.
+// Events for ignored paths are dropped.
+func (w *Watcher) process(event fsnotify.Event) ([]fsnotify.Event, error) {
+	if w.isIgnored(event.Name) || w.isOtherFile(event.Name) {
+		return nil, nil
+	}
+	if w.symlinks == SymlinkIgnore && isLink(event.Name) {
+		return nil, nil
+	}
+
+	events, err := w.watchTree(event)
+	for _, e := range events {
+		w.record(e.Name)
+	}
+	if w.symlinks == SymlinkFollow {
+		events = w.withAliases(events)
+	}
+	return events, err
+}
+
+// watchTree updates the watched directories according to an event, see [Watcher.process]
+func (w *Watcher) watchTree(event fsnotify.Event) ([]fsnotify.Event, error) {
+	events := []fsnotify.Event{event}
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		w.unwatch(event.Name)
+		if w.symlinks == SymlinkFollow {
+			w.unlink(event.Name)
 		}
-		if info.IsDir() {
-			err = w.Add(path)
+	}
+
+	if event.Has(fsnotify.Create) && w.isRecursive(event.Name) {
+		info, err := os.Lstat(event.Name)
+		if err == nil && info.Mode()&fs.ModeSymlink != 0 && w.symlinks == SymlinkFollow {
+			return w.followCreated(event)
+		}
+		if err != nil || !info.IsDir() {
+			return events, nil
+		}
+
+		err = filepath.WalkDir(event.Name, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
-				log.Fatal("error adding path:", path, err)
-			} else {
-				log.Println("watching path:", path)
+				// the directory may be gone already
+				return nil
+			}
+			if path != event.Name && w.ignore.Match(path, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
 			}
+			if path != event.Name {
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+			}
+			if d.IsDir() {
+				if err := w.Add(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return &AddError{Path: path, Err: err}
+				}
+			}
+			return nil
+		})
+		return events, err
+	}
+
+	return events, nil
+}
+
+// snapshotOpts returns the options of the snapshots of the roots
+func (w *Watcher) snapshotOpts() []func(*SnapshotOpts) {
+	opts := []func(*SnapshotOpts){WithSnapshotIgnore(w.ignore)}
+	if w.pollHash {
+		opts = append(opts, WithHash())
+	}
+	return opts
+}
+
+// snapshot returns the snapshot of the innermost root containing path, if any
+func (w *Watcher) snapshot(path string) *Snapshot {
+	var snapshot *Snapshot
+	for root, s := range w.snapshots {
+		if isUnder(path, root) && (snapshot == nil || len(root) > len(snapshot.Root)) {
+			snapshot = s
 		}
-		return nil
-	})
-	return err
+	}
+	return snapshot
 }
 
-func (w *Watcher) Watch(f func(fsnotify.Event), opts ...(func(*WatchOpts))) {
-	// apply options
-	options := &WatchOpts{}
-	for _, o := range opts {
-		o(options)
+// record updates the snapshot containing path with its current state
+func (w *Watcher) record(path string) {
+	if !w.rescanRoots {
+		return
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	s := w.snapshot(path)
+	w.mu.Unlock()
+	if s == nil {
+		return
 	}
 
-	for {
-		select {
+	// the file is read without holding the lock
+	entry, exists := stat(path, w.pollHash)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if s := w.snapshot(path); s != nil {
+		s.update(path, entry, exists)
+	}
+}
 
-		case event, ok := <-w.Events:
-			if !ok {
-				return
-			}
+// rescan takes a new snapshot of the roots,
+// and returns the events to go from the previous snapshots to the new ones.
+//
+// As for a directory created under a root, the contents of a created directory
+// are left to [Watcher.process], which also watches them.
+func (w *Watcher) rescan() ([]fsnotify.Event, error) {
+	w.mu.Lock()
+	roots := make([]string, 0, len(w.snapshots))
+	for root := range w.snapshots {
+		roots = append(roots, root)
+	}
+	w.mu.Unlock()
+	sort.Strings(roots)
 
-			if options.filter != nil && !options.filter(event) {
-				continue
-			}
+	var events []fsnotify.Event
+	var errs []error
+	for _, root := range roots {
+		current, err := TakeSnapshot(root, w.snapshotOpts()...)
+		if errors.Is(err, fs.ErrNotExist) {
+			current = &Snapshot{Root: root, Time: w.clock.Now(), Entries: map[string]Entry{}}
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
 
-			f(event)
+		w.mu.Lock()
+		changes := w.snapshots[root].Diff(current)
+		w.snapshots[root] = current
+		w.mu.Unlock()
 
-		case err, ok := <-w.Errors:
-			if !ok {
-				return
+		created := map[string]bool{}
+		for _, c := range changes {
+			switch c.Kind {
+			case Created:
+				created[c.Path] = true
+				if !created[filepath.Dir(c.Path)] {
+					events = append(events, fsnotify.Event{Name: c.Path, Op: fsnotify.Create})
+				}
+			case Modified:
+				events = append(events, fsnotify.Event{Name: c.Path, Op: fsnotify.Write})
+			case Deleted:
+				events = append(events, fsnotify.Event{Name: c.Path, Op: fsnotify.Remove})
+			case Renamed:
+				events = append(events,
+					fsnotify.Event{Name: c.From, Op: fsnotify.Rename},
+					fsnotify.Event{Name: c.Path, Op: fsnotify.Create},
+				)
 			}
-			log.Println("error:", err)
+		}
+	}
+	return events, errors.Join(errs...)
+}
 
-		case <-time.After(2 * time.Second):
-			log.Println("no events..")
+// sendRescan rescans the roots and sends the events and error.
+// It returns false if the watcher was closed.
+func (w *Watcher) sendRescan() bool {
+	events, err := w.rescan()
+	for _, e := range events {
+		select {
+		case w.Events <- e:
+		case <-w.done:
+			return false
+		}
+	}
+	if err != nil {
+		select {
+		case w.Errors <- err:
+		case <-w.done:
+			return false
 		}
 	}
+	return true
 }
 
-// WatchDedup is like [Watcher.Watch] but it waits for a given duration
-// before calling the function. This is a simple way to deduplicate events.
-func (w *Watcher) WatchDedup(d time.Duration, f func(fsnotify.Event), opts ...(func(*WatchOpts))) {
+// followCreated watches the directory targeted by a link created under a recursive root,
+// and returns a synthetic Create event for everything already inside it.
+// The events are under the real path of the directory, their aliases are added by [Watcher.process].
+func (w *Watcher) followCreated(event fsnotify.Event) ([]fsnotify.Event, error) {
+	events := []fsnotify.Event{event}
+	real := realDir(event.Name)
+	if real == "" {
+		return events, nil
+	}
+
+	a := w.newTreeAdder()
+	watched := a.visited[real]
+	if err := a.follow(event.Name); err != nil {
+		return events, err
+	}
+	if watched || isAncestor(real, event.Name) {
+		// the link is recorded, but its target is not new: another link or a cycle
+		return events, nil
+	}
+
+	_ = filepath.WalkDir(real, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == real {
+			return nil
+		}
+		events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		return nil
+	})
+	return events, a.err()
+}
+
+// isIgnored returns true if path is matched by the ignore patterns of the watcher
+func (w *Watcher) isIgnored(path string) bool {
+	if w.ignore == nil {
+		return false
+	}
+
+	var isDir bool
+	if info, err := os.Lstat(path); err == nil {
+		isDir = info.IsDir()
+	} else {
+		// the path may be gone already
+		w.mu.Lock()
+		isDir = w.watched[filepath.Clean(path)]
+		w.mu.Unlock()
+	}
+	return w.ignore.Match(path, isDir)
+}
+
+// unwatch removes the watches on path and its subdirectories
+func (w *Watcher) unwatch(path string) {
+	path = filepath.Clean(path)
+
+	w.mu.Lock()
+	var paths []string
+	for p := range w.watched {
+		if isUnder(p, path) {
+			paths = append(paths, p)
+			delete(w.watched, p)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, p := range paths {
+		// the kernel may have removed the watch already
+		_ = w.remove(p)
+	}
+}
+
+// Watch calls f for every event until the watcher is closed.
+// Errors are logged with the standard logger, unless [WithErrorHandler] is given.
+func (w *Watcher) Watch(f func(fsnotify.Event), opts ...(func(*WatchOpts))) {
+	opts = append([]func(*WatchOpts){WithErrorHandler(logError)}, opts...)
+	_ = w.WatchContext(context.Background(), f, opts...)
+}
+
+// WatchContext calls f for every event until the context is done or the watcher is closed.
+//
+// It returns the context error if the context is done, and nil if the watcher is closed.
+// Errors from the watcher are passed to the handler given with [WithErrorHandler],
+// or returned if there is none.
+func (w *Watcher) WatchContext(ctx context.Context, f func(fsnotify.Event), opts ...(func(*WatchOpts))) error {
 	// apply options
 	options := &WatchOpts{}
 	for _, o := range opts {
 		o(options)
 	}
 
-	var mu sync.Mutex
-	activeTimers := make(map[string]*time.Timer)
+	sub := w.Subscribe(opts...)
+	defer sub.Unsubscribe()
 
 	for {
 		select {
 
-		case err, ok := <-w.Errors:
-			if !ok {
-				return
-			}
-			log.Printf("ERROR: %s", err)
+		case <-ctx.Done():
+			return ctx.Err()
 
-		case e, ok := <-w.Events:
+		case event, ok := <-sub.Events:
 			if !ok {
-				return
-			}
-
-			if options.filter != nil && !options.filter(e) {
-				continue
+				return nil
 			}
+			f(event.Event)
 
-			// Get the timer associated to the event name (ie the file path)
-			mu.Lock()
-			t, ok := activeTimers[e.Name]
-			mu.Unlock()
-
-			// If this timer does not exist, create it with an arbitrary 1h expiration
+		case err, ok := <-sub.Errors:
 			if !ok {
-				t = time.AfterFunc(1*time.Hour, func() {
-					f(e)
-
-					// remove the timer after expiration
-					mu.Lock()
-					delete(activeTimers, e.Name)
-					mu.Unlock()
-				})
-
-				mu.Lock()
-				activeTimers[e.Name] = t
-				mu.Unlock()
+				return nil
+			}
+			if err = options.handleError(err); err != nil {
+				return err
 			}
-
-			// Start/reset the timer for this event name
-			t.Reset(d)
 		}
 	}
 }
 
-// Options for the [Watcher.Watch] and [Watcher.WatchDedup] methods.
+// WatchDedup is like [Watcher.Watch] but it waits for a given duration
+// before calling the function. This is a simple way to deduplicate events.
+func (w *Watcher) WatchDedup(d time.Duration, f func(fsnotify.Event), opts ...(func(*WatchOpts))) {
+	opts = append([]func(*WatchOpts){WithErrorHandler(logError)}, opts...)
+	_ = w.WatchDedupContext(context.Background(), d, f, opts...)
+}
+
+// WatchDedupContext is like [Watcher.WatchContext] but it waits for a given duration
+// before calling the function (see [WithDebounce]). Pending calls are canceled when it returns.
+func (w *Watcher) WatchDedupContext(ctx context.Context, d time.Duration, f func(fsnotify.Event), opts ...(func(*WatchOpts))) error {
+	opts = append(slices.Clone(opts), WithDebounce(d))
+	return w.WatchContext(ctx, f, opts...)
+}
+
+// Options for the [Watcher.Watch], [Watcher.WatchDedup] and [Watcher.WatchBatch] methods,
+// and for [Watcher.Subscribe].
 type WatchOpts struct {
-	filter func(fsnotify.Event) bool
+	filter       func(fsnotify.Event) bool
+	errorHandler func(error) error
+	maxWait      time.Duration
+
+	// subscription, see [Watcher.Subscribe]
+	prefix   string
+	debounce time.Duration
+	buffer   int
+	policy   Backpressure
+
+	fromStart bool // see [Watcher.Tail]
 }
 
 func WithFilter(filter func(fsnotify.Event) bool) func(*WatchOpts) {
@@ -142,3 +686,39 @@ func WithFilter(filter func(fsnotify.Event) bool) func(*WatchOpts) {
 		opts.filter = filter
 	}
 }
+
+// WithErrorHandler sets a function called with every error of the watcher.
+// If it returns an error, watching stops and the error is returned.
+func WithErrorHandler(handler func(error) error) func(*WatchOpts) {
+	return func(opts *WatchOpts) {
+		opts.errorHandler = handler
+	}
+}
+
+// handleError passes err to the error handler, if any.
+// It returns the error that should stop watching, if any.
+func (opts *WatchOpts) handleError(err error) error {
+	if err == nil || opts.errorHandler == nil {
+		return err
+	}
+	return opts.errorHandler(err)
+}
+
+func logError(err error) error {
+	log.Println("error:", err)
+	return nil
+}
+
+// AddError is returned when a path cannot be watched
+type AddError struct {
+	Path string // the path that could not be watched
+	Err  error
+}
+
+func (e *AddError) Error() string {
+	return fmt.Sprintf("error adding path %s: %v", e.Path, e.Err)
+}
+
+func (e *AddError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,191 @@
+package fswatch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func fsnotifyCreate(name string) fsnotify.Event {
+	return fsnotify.Event{Name: name, Op: fsnotify.Create}
+}
+
+// waitFor polls cond until it is true or the timeout expires
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAddRecursiveTracksDirectories(t *testing.T) {
+	root := t.TempDir()
+
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := make(chan fsnotify.Event, 100)
+	go w.Watch(func(e fsnotify.Event) { events <- e })
+
+	// nested tree created in one go, as with mkdir -p
+	nested := filepath.Join(root, "a", "b", "c")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	waitFor(t, "nested directories to be watched", func() bool {
+		return slices.Contains(w.Watched(), nested)
+	})
+
+	file := filepath.Join(nested, "file.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	waitFor(t, "an event in the new directory", func() bool {
+		for {
+			select {
+			case e := <-events:
+				if e.Name == file {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	})
+
+	if err := os.RemoveAll(filepath.Join(root, "a")); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	waitFor(t, "removed directories to be unwatched", func() bool {
+		return slices.Equal(w.Watched(), []string{root})
+	})
+}
+
+func TestProcessCreatedTree(t *testing.T) {
+	root := t.TempDir()
+
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the tree exists before its Create event is processed, as with git checkout
+	dir := filepath.Join(root, "pkg")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "x.go"), nil, 0o644); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+
+	events, err := w.process(fsnotify.Event{Name: dir, Op: fsnotify.Create})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, e := range events {
+		if e.Has(fsnotify.Create) {
+			got = append(got, e.Name)
+		}
+	}
+	expected := []string{dir, filepath.Join(dir, "sub"), filepath.Join(dir, "sub", "x.go")}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected Create events for %v, got %v", expected, got)
+	}
+	if !slices.Contains(w.Watched(), filepath.Join(dir, "sub")) {
+		t.Errorf("expected %s to be watched, got %v", filepath.Join(dir, "sub"), w.Watched())
+	}
+}
+
+func TestAddRecursiveError(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	err = w.AddRecursive(missing)
+
+	var addErr *AddError
+	if !errors.As(err, &addErr) {
+		t.Fatalf("expected AddError, got %v", err)
+	}
+	if addErr.Path != missing {
+		t.Errorf("expected path %s, got %s", missing, addErr.Path)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected error to wrap fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestWatchContext(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := w.WatchContext(ctx, func(fsnotify.Event) {}); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if err := w.WatchDedupContext(ctx, time.Millisecond, func(fsnotify.Event) {}); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("error handler", func(t *testing.T) {
+		errStop := errors.New("stop")
+		errs := make(chan error, 1)
+		go func() {
+			errs <- w.WatchContext(context.Background(), func(fsnotify.Event) {},
+				WithErrorHandler(func(err error) error {
+					if errors.Is(err, fsnotify.ErrEventOverflow) {
+						return errStop
+					}
+					return nil
+				}),
+			)
+		}()
+
+		w.Errors <- errors.New("ignored")
+		w.Errors <- fsnotify.ErrEventOverflow
+
+		select {
+		case err := <-errs:
+			if !errors.Is(err, errStop) {
+				t.Errorf("expected error from the handler, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for WatchContext to return")
+		}
+	})
+}
@@ -0,0 +1,260 @@
+// Package fswatchtest provides a fake clock and a fake event source,
+// for fast and deterministic tests of programs built on [fswatch.Watcher].
+//
+//	clock := fswatchtest.NewClock(time.Now())
+//	source := fswatchtest.NewSource()
+//	w, err := fswatch.NewWatcher(fswatch.WithClock(clock), fswatch.WithSource(source))
+//	...
+//	go w.WatchBatch(ctx, time.Second, f)
+//	source.Send(fsnotify.Event{Name: "a.go", Op: fsnotify.Write})
+//	clock.BlockUntil(1) // the batch is waiting for a quiet period
+//	clock.Advance(time.Second) // f is called
+package fswatchtest
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gforien/go/pkg/fswatch"
+)
+
+// Clock is a fake [fswatch.Clock] whose time only moves with [Clock.Advance]
+type Clock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond // signaled when timers change
+	now    time.Time
+	timers map[*timer]bool // active timers
+}
+
+type timer struct {
+	c    *Clock
+	when time.Time
+	ch   chan time.Time
+	f    func()
+}
+
+// NewClock returns a clock starting at now
+func NewClock(now time.Time) *Clock {
+	c := &Clock{now: now, timers: map[*timer]bool{}}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTimer(d time.Duration) fswatch.Timer {
+	t := &timer{c: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) fswatch.Timer {
+	t := &timer{c: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the time forward by d, firing the timers due in order.
+// The functions of [Clock.AfterFunc] have returned when it returns: unlike with the time package,
+// they run in the goroutine calling Advance, so they must not wait for it.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		var next *timer
+		for t := range c.timers {
+			if !t.when.After(end) && (next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		c.now = next.when
+		delete(c.timers, next)
+		if next.f != nil {
+			// f may use the clock
+			c.mu.Unlock()
+			next.f()
+			c.mu.Lock()
+		} else {
+			select {
+			case next.ch <- c.now:
+			default:
+			}
+		}
+	}
+	c.now = end
+	c.cond.Broadcast()
+}
+
+// Timers returns the number of active timers
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n timers are active,
+// e.g. until a watcher is waiting for a quiet period.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.ch
+}
+
+// Stop stops the timer. Like with the time package since Go 1.23,
+// a time sent on the channel and not received yet is discarded.
+func (t *timer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	active := t.c.timers[t]
+	delete(t.c.timers, t)
+	t.drain()
+	t.c.cond.Broadcast()
+	return active
+}
+
+// Reset restarts the timer, discarding a time not received yet like [timer.Stop]
+func (t *timer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	active := t.c.timers[t]
+	t.drain()
+	t.when = t.c.now.Add(d)
+	t.c.timers[t] = true
+	t.c.cond.Broadcast()
+	return active
+}
+
+// drain discards the time sent on the channel, if any
+func (t *timer) drain() {
+	if t.ch == nil {
+		return
+	}
+	select {
+	case <-t.ch:
+	default:
+	}
+}
+
+// Source is a fake [fswatch.Source] sending the events and errors given to it
+type Source struct {
+	events chan fsnotify.Event
+	errs   chan error
+	done   chan struct{}
+	once   sync.Once
+
+	mu      sync.RWMutex // held for reading while sending
+	closed  bool
+	watched map[string]bool
+}
+
+// NewSource returns a source sending nothing until told to
+func NewSource() *Source {
+	return &Source{
+		events:  make(chan fsnotify.Event),
+		errs:    make(chan error),
+		done:    make(chan struct{}),
+		watched: map[string]bool{},
+	}
+}
+
+func (s *Source) Events() <-chan fsnotify.Event { return s.events }
+func (s *Source) Errors() <-chan error          { return s.errs }
+
+func (s *Source) Add(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fsnotify.ErrClosed
+	}
+	s.watched[filepath.Clean(name)] = true
+	return nil
+}
+
+func (s *Source) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.watched[filepath.Clean(name)] {
+		return fmt.Errorf("%w: %s", fsnotify.ErrNonExistentWatch, name)
+	}
+	delete(s.watched, filepath.Clean(name))
+	return nil
+}
+
+func (s *Source) Close() error {
+	s.once.Do(func() {
+		// unblock the pending sends before taking the lock
+		close(s.done)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.events)
+		close(s.errs)
+	})
+	return nil
+}
+
+// Watched returns the sorted list of paths added and not removed
+func (s *Source) Watched() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	paths := make([]string, 0, len(s.watched))
+	for path := range s.watched {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Send sends events to the watcher, and returns once it received them.
+// Events sent after the source is closed are dropped.
+func (s *Source) Send(events ...fsnotify.Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	for _, e := range events {
+		select {
+		case s.events <- e:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// SendError sends an error to the watcher, and returns once it received it.
+func (s *Source) SendError(err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.errs <- err:
+	case <-s.done:
+	}
+}
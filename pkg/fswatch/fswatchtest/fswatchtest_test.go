@@ -0,0 +1,163 @@
+package fswatchtest_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gforien/go/pkg/fswatch"
+	"github.com/gforien/go/pkg/fswatch/fswatchtest"
+)
+
+func newWatcher(t *testing.T) (*fswatch.Watcher, *fswatchtest.Clock, *fswatchtest.Source) {
+	t.Helper()
+
+	clock := fswatchtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	source := fswatchtest.NewSource()
+	w, err := fswatch.NewWatcher(fswatch.WithClock(clock), fswatch.WithSource(source))
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w, clock, source
+}
+
+func TestWatchBatch(t *testing.T) {
+	w, clock, source := newWatcher(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan []fswatch.Change, 10)
+	go func() {
+		_ = w.WatchBatch(ctx, time.Second, func(changes []fswatch.Change) {
+			batches <- changes
+		})
+	}()
+
+	source.Send(fsnotify.Event{Name: "a.go", Op: fsnotify.Write})
+	clock.BlockUntil(1)
+
+	// not quiet for long enough
+	clock.Advance(time.Second - time.Nanosecond)
+	if clock.Timers() != 1 {
+		t.Errorf("expected the batch to be pending")
+	}
+
+	clock.Advance(time.Nanosecond)
+	select {
+	case changes := <-batches:
+		expected := []fswatch.Change{{Path: "a.go", Kind: fswatch.Modified}}
+		if !slices.Equal(changes, expected) {
+			t.Errorf("expected %v, got %v", expected, changes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the batch")
+	}
+}
+
+func TestWatchDedup(t *testing.T) {
+	w, clock, source := newWatcher(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan fsnotify.Event, 10)
+	go func() {
+		_ = w.WatchDedupContext(ctx, time.Minute, func(e fsnotify.Event) {
+			events <- e
+		})
+	}()
+
+	source.Send(
+		fsnotify.Event{Name: "a.go", Op: fsnotify.Write},
+		fsnotify.Event{Name: "b.go", Op: fsnotify.Write},
+	)
+	clock.BlockUntil(2)
+	clock.Advance(time.Minute)
+
+	var got []string
+	for range 2 {
+		select {
+		case e := <-events:
+			got = append(got, e.Name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for events, got %v", got)
+		}
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"a.go", "b.go"}) {
+		t.Errorf("expected [a.go b.go], got %v", got)
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	w, _, source := newWatcher(t)
+
+	errStop := errors.New("stop")
+	errs := make(chan error, 1)
+	go func() {
+		errs <- w.WatchContext(context.Background(), func(fsnotify.Event) {})
+	}()
+	source.SendError(errStop)
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errStop) {
+			t.Errorf("expected %v, got %v", errStop, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for WatchContext to return")
+	}
+
+	if err := w.Add("dir"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := source.Watched(); !slices.Equal(got, []string{"dir"}) {
+		t.Errorf("expected [dir], got %v", got)
+	}
+}
+
+func TestClock(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(fswatch.Timer)
+	}{
+		{name: "stop", stop: func(timer fswatch.Timer) { timer.Stop() }},
+		{name: "reset", stop: func(timer fswatch.Timer) { timer.Reset(time.Hour) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := fswatchtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			timer := clock.NewTimer(time.Second)
+			clock.Advance(time.Second)
+
+			// like time.Timer, the time not received yet is discarded
+			test.stop(timer)
+			select {
+			case now := <-timer.C():
+				t.Errorf("expected no time, got %v", now)
+			default:
+			}
+		})
+	}
+
+	t.Run("after func", func(t *testing.T) {
+		clock := fswatchtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		called := false
+		clock.AfterFunc(time.Second, func() {
+			called = true
+			// the clock can be used by the function
+			clock.AfterFunc(time.Second, func() {})
+		})
+		clock.Advance(time.Second)
+		if !called {
+			t.Errorf("expected the function to have run")
+		}
+		if clock.Timers() != 1 {
+			t.Errorf("expected 1 timer, got %d", clock.Timers())
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package fswatch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Event is a [fsnotify.Event] delivered by a [Subscription].
+//
+// With [WithContentHash], OldHash and NewHash are the SHA-256 of the content
+// before and after a Write or Chmod event, if known.
+type Event struct {
+	fsnotify.Event
+	OldHash string
+	NewHash string
+}
+
+// DefaultMaxHashSize is the size of the largest file hashed with [WithContentHash] when none is given
+const DefaultMaxHashSize = 16 << 20
+
+// hashDelay is how long a file must get no event before it is hashed, with [WithContentHash]
+const hashDelay = 50 * time.Millisecond
+
+// WithContentHash makes the watcher drop the Write and Chmod events of files whose content
+// and mode did not change (e.g. touch), and the Create events of files replaced as is.
+//
+// Files are hashed lazily, once they get no event for 50ms: their first event is delivered,
+// unless [WithHashOnAdd] is given. Files larger than maxSize bytes are not hashed.
+// If maxSize is zero or negative, [DefaultMaxHashSize] is used.
+func WithContentHash(maxSize int64) func(*Watcher) {
+	return func(w *Watcher) {
+		if maxSize <= 0 {
+			maxSize = DefaultMaxHashSize
+		}
+		w.maxHashSize = maxSize
+		w.hashes = map[string]contentHash{}
+		w.held = map[string]*heldEvent{}
+	}
+}
+
+// WithHashOnAdd makes [WithContentHash] hash in the background the files found by
+// [Watcher.AddRecursive] and [Watcher.AddFile], so that their first event is dropped
+// if they did not change. This reads the whole tree when it is added.
+func WithHashOnAdd() func(*Watcher) {
+	return func(w *Watcher) {
+		w.hashOnAdd = true
+	}
+}
+
+type contentHash struct {
+	hash    string
+	mode    fs.FileMode
+	removed bool // the file was removed or renamed since it was hashed
+}
+
+// heldEvent is an event held until its file settles, see [Watcher.hold]
+type heldEvent struct {
+	event   fsnotify.Event
+	timer   Timer
+	claimed bool          // by [Watcher.settle] or by a later event of the path, under hashMu
+	done    chan struct{} // closed once settled, if claimed by [Watcher.settle]
+}
+
+// hold returns the events to deliver at once for e.
+// It is only called by [Watcher.dispatch].
+//
+// With [WithContentHash], the Write and Chmod events and the Create events replacing a known file
+// are held until the file gets no event for [hashDelay], then delivered by [Watcher.settle]
+// if the file changed. The other events are delivered at once, after the event held for their path.
+func (w *Watcher) hold(e fsnotify.Event) []Event {
+	if w.hashes == nil {
+		return []Event{{Event: e}}
+	}
+	path := filepath.Clean(e.Name)
+	written := e.Op&^(fsnotify.Write|fsnotify.Chmod) == 0
+
+	w.hashMu.Lock()
+	defer w.hashMu.Unlock()
+	var events []Event
+	if h, ok := w.held[path]; ok {
+		if written && !h.claimed {
+			// the file is still being written
+			h.event.Op |= e.Op
+			h.timer.Reset(hashDelay)
+			return nil
+		}
+
+		delete(w.held, path)
+		if h.claimed {
+			// being hashed: wait for its event to be delivered first
+			w.hashMu.Unlock()
+			<-h.done
+			w.hashMu.Lock()
+		} else {
+			h.claimed = true
+			h.timer.Stop()
+			events = append(events, Event{Event: h.event})
+		}
+	}
+
+	old, known := w.hashes[path]
+	switch {
+	case e.Has(fsnotify.Remove) || e.Has(fsnotify.Rename):
+		if known {
+			old.removed = true
+			w.hashes[path] = old
+		}
+		return append(events, Event{Event: e})
+
+	case e.Has(fsnotify.Create) && (!known || old.removed):
+		return append(events, Event{Event: e})
+	}
+
+	h := &heldEvent{event: e, done: make(chan struct{})}
+	h.timer = w.clock.AfterFunc(hashDelay, func() {
+		w.settle(path, h)
+	})
+	w.held[path] = h
+	return events
+}
+
+// settle hashes the file of an event held by [Watcher.hold],
+// and delivers the event if the content or mode of the file changed.
+// It runs in the goroutine of the timer, not to block the dispatch of other events.
+func (w *Watcher) settle(path string, h *heldEvent) {
+	w.hashMu.Lock()
+	if h.claimed {
+		w.hashMu.Unlock()
+		return
+	}
+	h.claimed = true
+	if w.held[path] == h {
+		delete(w.held, path)
+	}
+	w.hashMu.Unlock()
+	defer close(h.done)
+
+	e, changed := w.hash(path, h.event)
+	if !changed {
+		return
+	}
+	for _, s := range w.subscriptions() {
+		s.send(e)
+	}
+}
+
+// hash returns the event with the hashes of the file, and false if its content and mode did not change
+func (w *Watcher) hash(path string, e fsnotify.Event) (Event, bool) {
+	event := Event{Event: e}
+	current, ok := hashPath(path, w.maxHashSize)
+
+	w.hashMu.Lock()
+	defer w.hashMu.Unlock()
+	old, known := w.hashes[path]
+	if !ok {
+		delete(w.hashes, path)
+		return event, true
+	}
+	w.hashes[path] = current
+	event.OldHash, event.NewHash = old.hash, current.hash
+	return event, !known || old.hash != current.hash || old.mode != current.mode
+}
+
+// stopHeld drops the held events, once the watcher is closed
+func (w *Watcher) stopHeld() {
+	w.hashMu.Lock()
+	defer w.hashMu.Unlock()
+	for path, h := range w.held {
+		if !h.claimed {
+			h.claimed = true
+			h.timer.Stop()
+		}
+		delete(w.held, path)
+	}
+}
+
+// seed hashes in the background the regular files found when adding paths, see [WithHashOnAdd].
+// A file is skipped if it was modified since it was found, or hashed already.
+func (w *Watcher) seed(files map[string]fs.FileInfo) {
+	if !w.seeds() || len(files) == 0 {
+		return
+	}
+
+	w.seeding.Add(1)
+	go func() {
+		defer w.seeding.Done()
+		for path, found := range files {
+			if w.closed() {
+				return
+			}
+			current, ok := hashPath(path, w.maxHashSize)
+			if !ok {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil || info.Size() != found.Size() || !info.ModTime().Equal(found.ModTime()) {
+				continue
+			}
+
+			w.hashMu.Lock()
+			if _, known := w.hashes[path]; !known {
+				w.hashes[path] = current
+			}
+			w.hashMu.Unlock()
+		}
+	}()
+}
+
+// seeds returns true if the files found when adding paths are hashed
+func (w *Watcher) seeds() bool {
+	return w.hashes != nil && w.hashOnAdd
+}
+
+// hashPath returns the hash and mode of the file at path,
+// and false if it is not a regular file of at most maxSize bytes
+func hashPath(path string, maxSize int64) (contentHash, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxSize {
+		return contentHash{}, false
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return contentHash{}, false
+	}
+	return contentHash{hash: hash, mode: info.Mode()}, true
+}
@@ -0,0 +1,207 @@
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestWithContentHash(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	large := filepath.Join(dir, "large.txt")
+	created := filepath.Join(dir, "new.txt")
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "large.txt": "too large"})
+
+	w, err := NewWatcher(WithSource(newLimitedBackend(100)), WithContentHash(4), WithHashOnAdd())
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.AddRecursive(dir); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	// the files found by AddRecursive are hashed in the background
+	w.seeding.Wait()
+	sub := w.Subscribe()
+
+	hashA, err := hashFile(path)
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	write := func(content string) func(t *testing.T) {
+		return func(t *testing.T) { writeFiles(t, dir, map[string]string{"a.txt": content}) }
+	}
+	event := func(name string, op fsnotify.Op) fsnotify.Event {
+		return fsnotify.Event{Name: name, Op: op}
+	}
+
+	type test struct {
+		name      string
+		change    func(t *testing.T)
+		events    []fsnotify.Event
+		delivered []fsnotify.Event
+		oldHash   string
+	}
+	tests := []test{
+		{
+			name:   "first touch of a seeded file",
+			events: []fsnotify.Event{event(path, fsnotify.Chmod)},
+		},
+		{
+			name:   "rewritten as is",
+			change: write("a"),
+			events: []fsnotify.Event{event(path, fsnotify.Write)},
+		},
+		{
+			name:      "modified",
+			change:    write("b"),
+			events:    []fsnotify.Event{event(path, fsnotify.Write)},
+			delivered: []fsnotify.Event{event(path, fsnotify.Write)},
+			oldHash:   hashA,
+		},
+		{
+			name: "written in chunks",
+			change: func(t *testing.T) {
+				for _, content := range []string{"", "c", "cd"} {
+					write(content)(t)
+					w.Events <- event(path, fsnotify.Write)
+				}
+			},
+			events:    []fsnotify.Event{event(path, fsnotify.Chmod)},
+			delivered: []fsnotify.Event{event(path, fsnotify.Write|fsnotify.Chmod)},
+		},
+		{
+			name:      "too large",
+			events:    []fsnotify.Event{event(large, fsnotify.Write)},
+			delivered: []fsnotify.Event{event(large, fsnotify.Write)},
+		},
+		{
+			name:      "too large again",
+			events:    []fsnotify.Event{event(large, fsnotify.Write)},
+			delivered: []fsnotify.Event{event(large, fsnotify.Write)},
+		},
+		{
+			name:      "removed",
+			change:    func(t *testing.T) { remove(t, path) },
+			events:    []fsnotify.Event{event(path, fsnotify.Remove)},
+			delivered: []fsnotify.Event{event(path, fsnotify.Remove)},
+		},
+		{
+			name:      "created again as is",
+			change:    write("cd"),
+			events:    []fsnotify.Event{event(path, fsnotify.Create), event(path, fsnotify.Write)},
+			delivered: []fsnotify.Event{event(path, fsnotify.Create)},
+		},
+		{
+			name: "replaced as is",
+			change: func(t *testing.T) {
+				writeFiles(t, dir, map[string]string{"a.tmp": "cd"})
+				if err := os.Rename(filepath.Join(dir, "a.tmp"), path); err != nil {
+					t.Fatalf("error in test setup: %v", err)
+				}
+			},
+			events: []fsnotify.Event{event(path, fsnotify.Create)},
+		},
+		{
+			name: "replaced",
+			change: func(t *testing.T) {
+				writeFiles(t, dir, map[string]string{"a.tmp": "e"})
+				if err := os.Rename(filepath.Join(dir, "a.tmp"), path); err != nil {
+					t.Fatalf("error in test setup: %v", err)
+				}
+			},
+			events:    []fsnotify.Event{event(path, fsnotify.Create)},
+			delivered: []fsnotify.Event{event(path, fsnotify.Create)},
+		},
+		{
+			name:      "new file",
+			change:    func(t *testing.T) { writeFiles(t, dir, map[string]string{"new.txt": "n"}) },
+			events:    []fsnotify.Event{event(created, fsnotify.Create), event(created, fsnotify.Write)},
+			delivered: []fsnotify.Event{event(created, fsnotify.Create), event(created, fsnotify.Write)},
+		},
+		{
+			name: "mode changed",
+			change: func(t *testing.T) {
+				if err := os.Chmod(path, 0o600); err != nil {
+					t.Fatalf("error in test setup: %v", err)
+				}
+			},
+			events:    []fsnotify.Event{event(path, fsnotify.Chmod)},
+			delivered: []fsnotify.Event{event(path, fsnotify.Chmod)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.change != nil {
+				test.change(t)
+			}
+			for _, e := range test.events {
+				w.Events <- e
+			}
+
+			for _, expected := range test.delivered {
+				got := receive(t, sub)
+				if got.Event != expected {
+					t.Fatalf("expected %v, got %v", expected, got)
+				}
+				if test.oldHash != "" && got.OldHash != test.oldHash {
+					t.Errorf("expected old hash %s, got %s", test.oldHash, got.OldHash)
+				}
+				if got.Name == path && got.Has(fsnotify.Write) {
+					if expected, _ := hashFile(path); got.NewHash != expected {
+						t.Errorf("expected new hash %s, got %s", expected, got.NewHash)
+					}
+				}
+			}
+			select {
+			case got := <-sub.Events:
+				t.Errorf("expected no other event, got %v", got)
+			case <-time.After(4 * hashDelay):
+			}
+		})
+	}
+}
+
+// TestWithContentHashLazy checks that files are only hashed on their first event by default
+func TestWithContentHashLazy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+
+	w, err := NewWatcher(WithSource(newLimitedBackend(100)), WithContentHash(0))
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.AddRecursive(dir); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	if err := w.AddFile(path); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	w.seeding.Wait()
+	w.hashMu.Lock()
+	hashed := len(w.hashes)
+	w.hashMu.Unlock()
+	if hashed != 0 {
+		t.Errorf("expected no file hashed when added, got %d", hashed)
+	}
+
+	sub := w.Subscribe()
+	touch := fsnotify.Event{Name: path, Op: fsnotify.Chmod}
+	w.Events <- touch
+	if got := receive(t, sub); got.Event != touch {
+		t.Errorf("expected %v, got %v", touch, got)
+	}
+	w.Events <- touch
+	select {
+	case got := <-sub.Events:
+		t.Errorf("expected the second touch to be dropped, got %v", got)
+	case <-time.After(4 * hashDelay):
+	}
+}
@@ -0,0 +1,272 @@
+package fswatch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CommonIgnores are patterns for files that are rarely worth watching:
+// VCS metadata, dependencies and editor temporary files.
+var CommonIgnores = []string{
+	".git/", ".hg/", ".svn/", "node_modules/",
+	"*.swp", "*.swx", "*~", ".#*", "#*#", "4913", ".DS_Store",
+}
+
+// Ignore matches paths against gitignore-style patterns.
+// See https://git-scm.com/docs/gitignore
+//
+// As with git, the last matching pattern wins, patterns of nested ignore files
+// take precedence over the ones of their parents, and a file cannot be
+// re-included with a negated pattern if one of its parent directories is ignored.
+//
+// A nil *Ignore matches nothing.
+type Ignore struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	base    string // absolute directory the pattern is relative to
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// NewIgnore returns an Ignore with the given patterns, relative to base.
+func NewIgnore(base string, patterns ...string) (*Ignore, error) {
+	ig := &Ignore{}
+	if err := ig.Add(base, patterns...); err != nil {
+		return nil, err
+	}
+	return ig, nil
+}
+
+// Add adds patterns relative to base.
+// Blank lines and comments are skipped, so lines of an ignore file can be given as is.
+func (ig *Ignore) Add(base string, patterns ...string) error {
+	return ig.add(base, false, patterns)
+}
+
+// Merge adds the patterns of other, which take precedence over the existing ones.
+func (ig *Ignore) Merge(other *Ignore) {
+	if other != nil {
+		ig.patterns = append(ig.patterns, other.patterns...)
+	}
+}
+
+func (ig *Ignore) add(base string, anchored bool, patterns []string) error {
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return err
+	}
+	for _, line := range patterns {
+		p, ok, err := parsePattern(line, anchored)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", line, err)
+		}
+		if ok {
+			p.base = base
+			ig.patterns = append(ig.patterns, p)
+		}
+	}
+	return nil
+}
+
+// LoadIgnore reads the given ignore files.
+// Patterns are relative to the directory containing the file.
+//
+// Files named .dockerignore follow Docker semantics: every pattern is
+// relative to the directory containing the file, even without a slash.
+func LoadIgnore(files ...string) (*Ignore, error) {
+	ig := &Ignore{}
+	for _, file := range files {
+		if err := ig.load(file); err != nil {
+			return nil, err
+		}
+	}
+	return ig, nil
+}
+
+// LoadIgnoreTree reads the ignore files with the given names (default .gitignore)
+// in root and all its subdirectories. Ignored directories are not visited.
+// Missing ignore files are skipped.
+func LoadIgnoreTree(root string, names ...string) (*Ignore, error) {
+	if len(names) == 0 {
+		names = []string{".gitignore"}
+	}
+
+	ig := &Ignore{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && ig.Match(path, true) {
+			return filepath.SkipDir
+		}
+		for _, name := range names {
+			err := ig.load(filepath.Join(path, name))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ig, nil
+}
+
+func (ig *Ignore) load(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	lines, err := readLines(f)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", file, err)
+	}
+	anchored := filepath.Base(file) == ".dockerignore"
+	return ig.add(filepath.Dir(file), anchored, lines)
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// Match returns true if path is ignored.
+// isDir tells whether path is a directory, for patterns with a trailing slash.
+func (ig *Ignore) Match(path string, isDir bool) bool {
+	if ig == nil || len(ig.patterns) == 0 {
+		return false
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	// a path is ignored if one of its parents is ignored
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if ig.match(dir, true) {
+			return true
+		}
+	}
+	return ig.match(path, isDir)
+}
+
+// match returns the result of the last pattern matching path, without looking at its parents
+func (ig *Ignore) match(path string, isDir bool) bool {
+	ignored := false
+	for _, p := range ig.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(p.base, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if p.re.MatchString(filepath.ToSlash(rel)) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// parsePattern parses a line of an ignore file.
+// It returns false if the line is blank or a comment.
+func parsePattern(line string, anchored bool) (ignorePattern, bool, error) {
+	var p ignorePattern
+
+	// trailing spaces are ignored unless escaped
+	if strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line[:len(line)-2], " ") + `\ `
+	} else {
+		line = strings.TrimRight(line, " \t\r")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false, nil
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// a slash at the beginning or in the middle anchors the pattern to its base
+	if strings.Contains(line, "/") {
+		anchored = true
+	}
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return p, false, nil
+	}
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return p, false, err
+	}
+	p.re = re
+	return p, true, nil
+}
+
+// globToRegexp translates a gitignore glob to a regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			// leading or middle "**/" matches zero or more directories
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIgnoreMatch(t *testing.T) {
+	base := t.TempDir()
+	ig, err := NewIgnore(base,
+		"# comment",
+		"",
+		"*.log",
+		"!keep.log",
+		"/build/",
+		"docs/*.html",
+		"**/tmp/**",
+		"node_modules/",
+		"a/**/z",
+		`\#literal`,
+		"dist",
+		"!dist/keep.txt",
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{path: "app.log", expected: true},
+		{path: "sub/dir/app.log", expected: true},
+		{path: "keep.log", expected: false},
+		{path: "sub/keep.log", expected: false},
+		{path: "build", isDir: true, expected: true},
+		{path: "build/out.bin", expected: true},
+		{path: "build", isDir: false, expected: false},
+		{path: "sub/build", isDir: true, expected: false},
+		{path: "docs/index.html", expected: true},
+		{path: "docs/api/index.html", expected: false},
+		{path: "x/tmp/y/z.txt", expected: true},
+		{path: "node_modules/pkg/index.js", expected: true},
+		{path: "web/node_modules", isDir: true, expected: true},
+		{path: "a/z", expected: true},
+		{path: "a/b/c/z", expected: true},
+		{path: "#literal", expected: true},
+		{path: "main.go", expected: false},
+		// a file cannot be re-included if its parent directory is ignored
+		{path: "dist/keep.txt", expected: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+
+			if got := ig.Match(filepath.Join(base, tc.path), tc.isDir); got != tc.expected {
+				t.Errorf("Match(%s, %v): expected %v, got %v", tc.path, tc.isDir, tc.expected, got)
+			}
+		})
+	}
+
+	if ig.Match(filepath.Join(filepath.Dir(base), "app.log"), false) {
+		t.Errorf("expected patterns not to apply outside of their base directory")
+	}
+}
+
+// writeFiles creates the given files under root
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("error in test setup: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("error in test setup: %v", err)
+		}
+	}
+}
+
+func TestLoadIgnoreTree(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":          "*.out\nvendor/\n",
+		"vendor/.gitignore":   "!*.out\n",
+		"pkg/.gitignore":      "!keep.out\ngen/\n",
+		"pkg/keep.out":        "",
+		"pkg/gen/x.go":        "",
+		"pkg/main.go":         "",
+		"other/.dockerignore": "secret\n",
+	})
+
+	ig, err := LoadIgnoreTree(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "a.out", expected: true},
+		{path: "pkg/b.out", expected: true},
+		{path: "pkg/keep.out", expected: false},
+		{path: "pkg/gen/x.go", expected: true},
+		{path: "pkg/main.go", expected: false},
+		// the ignore file of an ignored directory is not read
+		{path: "vendor/c.out", expected: true},
+	}
+	for _, tc := range cases {
+		if got := ig.Match(filepath.Join(root, tc.path), false); got != tc.expected {
+			t.Errorf("Match(%s): expected %v, got %v", tc.path, tc.expected, got)
+		}
+	}
+
+	docker, err := LoadIgnore(filepath.Join(root, "other", ".dockerignore"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !docker.Match(filepath.Join(root, "other", "secret"), false) {
+		t.Errorf("expected other/secret to be ignored")
+	}
+	if docker.Match(filepath.Join(root, "other", "sub", "secret"), false) {
+		t.Errorf("expected .dockerignore patterns to be anchored")
+	}
+}
+
+func TestWithIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"src/main.go":               "",
+		"node_modules/pkg/index.js": "",
+		".git/HEAD":                 "",
+	})
+
+	ig, err := NewIgnore(root, CommonIgnores...)
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	w, err := NewWatcher(WithIgnore(ig))
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{root, filepath.Join(root, "src")}
+	if got := w.Watched(); !slices.Equal(got, expected) {
+		t.Errorf("expected watched %v, got %v", expected, got)
+	}
+
+	swap := filepath.Join(root, "src", ".main.go.swp")
+	writeFiles(t, root, map[string]string{"src/.main.go.swp": ""})
+	if events, _ := w.process(fsnotifyCreate(swap)); len(events) != 0 {
+		t.Errorf("expected events for %s to be dropped, got %v", swap, events)
+	}
+	if events, _ := w.process(fsnotifyCreate(filepath.Join(root, "src", "main.go"))); len(events) != 1 {
+		t.Errorf("expected one event for main.go, got %v", events)
+	}
+}
@@ -0,0 +1,54 @@
+package fswatch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WatchLimitError is returned when the OS cannot watch more paths,
+// e.g. when fs.inotify.max_user_watches is reached on Linux.
+type WatchLimitError struct {
+	Setting   string // the OS setting of the limit, if known
+	Limit     int    // the current value of the setting, or 0 if unknown
+	Held      int    // the number of paths watched by the watcher
+	Remaining int    // the number of directories which could not be watched, with AddRecursive
+	Err       error  // the error of the OS, e.g. ENOSPC or EMFILE
+}
+
+func newWatchLimitError(err error, held int) *WatchLimitError {
+	setting, limit := watchLimit()
+	return &WatchLimitError{Setting: setting, Limit: limit, Held: held, Err: err}
+}
+
+func newInstanceLimitError(err error) *WatchLimitError {
+	setting, limit := instanceLimit()
+	return &WatchLimitError{Setting: setting, Limit: limit, Err: err}
+}
+
+func (e *WatchLimitError) Error() string {
+	var details []string
+	if e.Setting != "" && e.Limit > 0 {
+		details = append(details, fmt.Sprintf("%s = %d", e.Setting, e.Limit))
+	}
+	if e.Held > 0 {
+		details = append(details, fmt.Sprintf("%d paths watched", e.Held))
+	}
+	if e.Remaining > 0 {
+		details = append(details, fmt.Sprintf("%d directories remaining", e.Remaining))
+	}
+
+	var b strings.Builder
+	b.WriteString("watch limit reached")
+	if len(details) > 0 {
+		b.WriteString(" (" + strings.Join(details, ", ") + ")")
+	}
+	b.WriteString(": " + e.Err.Error())
+	if e.Setting != "" {
+		fmt.Fprintf(&b, "; raise %s or use polling", e.Setting)
+	}
+	return b.String()
+}
+
+func (e *WatchLimitError) Unwrap() error {
+	return e.Err
+}
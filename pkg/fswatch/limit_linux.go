@@ -0,0 +1,25 @@
+package fswatch
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+func watchLimit() (string, int) {
+	return "fs.inotify.max_user_watches", readLimit("/proc/sys/fs/inotify/max_user_watches")
+}
+
+func instanceLimit() (string, int) {
+	return "fs.inotify.max_user_instances", readLimit("/proc/sys/fs/inotify/max_user_instances")
+}
+
+// readLimit returns the integer in a file, or 0
+func readLimit(file string) int {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	return n
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package fswatch
+
+func watchLimit() (string, int) {
+	return "", 0
+}
+
+func instanceLimit() (string, int) {
+	return "", 0
+}
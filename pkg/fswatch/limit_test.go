@@ -0,0 +1,120 @@
+package fswatch
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// limitedBackend fails with ENOSPC after a number of watches, like inotify
+type limitedBackend struct {
+	limit   int
+	watches map[string]bool
+	evs     chan fsnotify.Event
+	errs    chan error
+}
+
+func newLimitedBackend(limit int) *limitedBackend {
+	return &limitedBackend{
+		limit:   limit,
+		watches: map[string]bool{},
+		evs:     make(chan fsnotify.Event),
+		errs:    make(chan error),
+	}
+}
+
+func (b *limitedBackend) Add(name string) error {
+	if len(b.watches) >= b.limit {
+		return fmt.Errorf("inotify_add_watch: %w", syscall.ENOSPC)
+	}
+	b.watches[name] = true
+	return nil
+}
+
+func (b *limitedBackend) Remove(name string) error {
+	if !b.watches[name] {
+		return fsnotify.ErrNonExistentWatch
+	}
+	delete(b.watches, name)
+	return nil
+}
+
+func (b *limitedBackend) Close() error {
+	close(b.evs)
+	close(b.errs)
+	return nil
+}
+
+func (b *limitedBackend) Events() <-chan fsnotify.Event { return b.evs }
+func (b *limitedBackend) Errors() <-chan error          { return b.errs }
+
+func TestWatchLimitError(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a/1/x": "", "a/2/x": "", "b/x": "", "c/x": "",
+	})
+
+	w, err := NewWatcher(WithSource(newLimitedBackend(3)))
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	err = w.AddRecursive(root)
+
+	var addErr *AddError
+	var limitErr *WatchLimitError
+	if !errors.As(err, &addErr) || !errors.As(err, &limitErr) {
+		t.Fatalf("expected AddError wrapping WatchLimitError, got %v", err)
+	}
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("expected error to wrap ENOSPC, got %v", err)
+	}
+	// root, a and a/1 are watched
+	if addErr.Path != filepath.Join(root, "a", "2") {
+		t.Errorf("expected path %s, got %s", filepath.Join(root, "a", "2"), addErr.Path)
+	}
+	if limitErr.Held != 3 {
+		t.Errorf("expected 3 watches held, got %d", limitErr.Held)
+	}
+	// a/2, b and c
+	if limitErr.Remaining != 3 {
+		t.Errorf("expected 3 directories remaining, got %d", limitErr.Remaining)
+	}
+	if !strings.Contains(err.Error(), "3 directories remaining") {
+		t.Errorf("expected error message to report the remaining directories, got %q", err)
+	}
+}
+
+func TestPollingFallback(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a/x": "", "b/x": ""})
+
+	w, err := NewWatcher(WithSource(newLimitedBackend(2)), WithPollingFallback(time.Hour))
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(w.Watched()) != 3 {
+		t.Errorf("expected 3 watched directories, got %v", w.Watched())
+	}
+	p := w.fallback.(*poller)
+	if _, ok := p.watches[filepath.Join(root, "b")]; !ok {
+		t.Errorf("expected %s to be polled", filepath.Join(root, "b"))
+	}
+
+	// removing a polled path removes it from the fallback
+	if err := w.Remove(filepath.Join(root, "b")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package fswatch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// poller is a backend which scans the watched paths at a regular interval,
+// for filesystems where fsnotify receives nothing (NFS, SMB, some bind mounts, FUSE).
+//
+// Files are compared by identity (inode), size, modification time and mode,
+// and optionally by a hash of their content.
+type poller struct {
+	interval time.Duration
+	hash     bool
+
+	evs  chan fsnotify.Event
+	errs chan error
+	done chan struct{}
+	once sync.Once
+
+	mu      sync.Mutex
+	watches map[string]map[string]fileState // watched path -> entries by path
+}
+
+type fileState struct {
+	info os.FileInfo
+	hash string
+}
+
+func newPoller(interval time.Duration, hash bool) *poller {
+	p := &poller{
+		interval: interval,
+		hash:     hash,
+		evs:      make(chan fsnotify.Event),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+		watches:  map[string]map[string]fileState{},
+	}
+	go p.run()
+	return p
+}
+
+func (p *poller) Events() <-chan fsnotify.Event { return p.evs }
+func (p *poller) Errors() <-chan error          { return p.errs }
+
+func (p *poller) Add(name string) error {
+	name = filepath.Clean(name)
+	entries, err := p.scan(name)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.done:
+		return fsnotify.ErrClosed
+	default:
+	}
+	if _, ok := p.watches[name]; !ok {
+		p.watches[name] = entries
+	}
+	return nil
+}
+
+func (p *poller) Remove(name string) error {
+	name = filepath.Clean(name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.watches[name]; !ok {
+		return fmt.Errorf("%w: %s", fsnotify.ErrNonExistentWatch, name)
+	}
+	delete(p.watches, name)
+	return nil
+}
+
+func (p *poller) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
+
+func (p *poller) run() {
+	defer close(p.evs)
+	defer close(p.errs)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			if !p.poll() {
+				return
+			}
+		}
+	}
+}
+
+// poll scans every watched path once and sends the events.
+// It returns false if the poller was closed.
+func (p *poller) poll() bool {
+	p.mu.Lock()
+	names := make([]string, 0, len(p.watches))
+	for name := range p.watches {
+		names = append(names, name)
+	}
+	p.mu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		entries, err := p.scan(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			if !p.sendError(err) {
+				return false
+			}
+			continue
+		}
+
+		p.mu.Lock()
+		old, ok := p.watches[name]
+		if ok {
+			if entries == nil {
+				// like inotify, a removed path is not watched anymore
+				delete(p.watches, name)
+			} else {
+				p.watches[name] = entries
+			}
+		}
+		p.mu.Unlock()
+		if !ok {
+			// removed meanwhile
+			continue
+		}
+
+		for _, e := range diff(old, entries) {
+			if !p.send(e) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *poller) send(e fsnotify.Event) bool {
+	select {
+	case p.evs <- e:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+func (p *poller) sendError(err error) bool {
+	select {
+	case p.errs <- err:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// scan returns the state of name, and of its direct children if it is a directory.
+// It returns a nil map and an error wrapping fs.ErrNotExist if name does not exist.
+func (p *poller) scan(name string) (map[string]fileState, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]fileState{name: p.state(name, info)}
+	if !info.IsDir() {
+		return entries, nil
+	}
+
+	dirEntries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirEntries {
+		info, err := d.Info()
+		if err != nil {
+			// removed meanwhile
+			continue
+		}
+		path := filepath.Join(name, d.Name())
+		entries[path] = p.state(path, info)
+	}
+	return entries, nil
+}
+
+func (p *poller) state(path string, info os.FileInfo) fileState {
+	s := fileState{info: info}
+	if p.hash && info.Mode().IsRegular() {
+		s.hash, _ = hashFile(path)
+	}
+	return s
+}
+
+// diff returns the events to go from the old to the new entries, sorted by path
+func diff(old, new map[string]fileState) []fsnotify.Event {
+	var events []fsnotify.Event
+	for path, o := range old {
+		n, ok := new[path]
+		switch {
+		case !ok:
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+		case !os.SameFile(o.info, n.info):
+			// replaced
+			events = append(events,
+				fsnotify.Event{Name: path, Op: fsnotify.Remove},
+				fsnotify.Event{Name: path, Op: fsnotify.Create},
+			)
+		case o.info.IsDir() && n.info.IsDir():
+			// the modification time of a directory changes with its entries
+			if o.info.Mode() != n.info.Mode() {
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+			}
+		case o.info.Size() != n.info.Size() || !o.info.ModTime().Equal(n.info.ModTime()) || o.hash != n.hash:
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+		case o.info.Mode() != n.info.Mode():
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+		}
+	}
+	for path := range new {
+		if _, ok := old[path]; !ok {
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Name < events[j].Name
+	})
+	return events
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
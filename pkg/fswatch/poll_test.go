@@ -0,0 +1,156 @@
+package fswatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestPolling(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+
+	w, err := NewWatcher(WithPolling(10 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan []Change, 10)
+	go func() {
+		_ = w.WatchBatch(ctx, 50*time.Millisecond, func(changes []Change) {
+			batches <- changes
+		})
+	}()
+
+	writeFiles(t, root, map[string]string{"a.txt": "modified", "c.txt": "c", "new/d.txt": "d"})
+	if err := os.Remove(filepath.Join(root, "sub", "b.txt")); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+
+	expected := map[string]ChangeKind{
+		filepath.Join(root, "a.txt"):        Modified,
+		filepath.Join(root, "c.txt"):        Created,
+		filepath.Join(root, "new"):          Created,
+		filepath.Join(root, "new", "d.txt"): Created,
+		filepath.Join(root, "sub", "b.txt"): Deleted,
+	}
+	got := map[string]ChangeKind{}
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(expected) {
+		select {
+		case changes := <-batches:
+			for _, c := range changes {
+				if c.Path != filepath.Join(root, "sub") {
+					got[c.Path] = c.Kind
+				}
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for changes, got %v", got)
+		}
+	}
+	for path, kind := range expected {
+		if got[path] != kind {
+			t.Errorf("expected %s to be %v, got %v", path, kind, got[path])
+		}
+	}
+	if !slices.Contains(w.Watched(), filepath.Join(root, "new")) {
+		t.Errorf("expected new directory to be watched, got %v", w.Watched())
+	}
+}
+
+func TestPollHash(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	writeFiles(t, root, map[string]string{"a.txt": "aaa"})
+	mtime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+
+	p := newPoller(time.Hour, true)
+	defer p.Close()
+	if err := p.Add(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// same size and modification time, different content
+	writeFiles(t, root, map[string]string{"a.txt": "bbb"})
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+
+	events := make(chan fsnotify.Event, 10)
+	go func() {
+		for e := range p.Events() {
+			events <- e
+		}
+	}()
+	p.poll()
+
+	select {
+	case e := <-events:
+		if e.Name != file || !e.Has(fsnotify.Write) {
+			t.Errorf("expected a Write event for %s, got %v", file, e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for an event")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a": "a", "b": "b", "c": "c"})
+
+	p := newPoller(time.Hour, false)
+	defer p.Close()
+	old, err := p.scan(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(root, "a")); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(root, "b"), 0o600); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	// replaced by another file
+	writeFiles(t, root, map[string]string{"c.tmp": "c", "d": "d"})
+	if err := os.Rename(filepath.Join(root, "c.tmp"), filepath.Join(root, "c")); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+
+	new, err := p.scan(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []fsnotify.Event
+	for _, e := range diff(old, new) {
+		if e.Name != root {
+			got = append(got, e)
+		}
+	}
+	expected := []fsnotify.Event{
+		{Name: filepath.Join(root, "a"), Op: fsnotify.Remove},
+		{Name: filepath.Join(root, "b"), Op: fsnotify.Chmod},
+		{Name: filepath.Join(root, "c"), Op: fsnotify.Remove},
+		{Name: filepath.Join(root, "c"), Op: fsnotify.Create},
+		{Name: filepath.Join(root, "d"), Op: fsnotify.Create},
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
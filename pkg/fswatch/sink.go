@@ -0,0 +1,114 @@
+package fswatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Sink writes changes to a stream, for other programs to consume.
+// Its Write method can be given to [Watcher.WatchBatch] through a closure.
+type Sink interface {
+	Write(changes []Change) error
+}
+
+// Record is a change as written by the sink of [NewJSONSink]
+type Record struct {
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	Path    string    `json:"path"` // absolute
+	RelPath string    `json:"rel_path"`
+	From    string    `json:"from,omitempty"` // the previous path, for renamed changes
+	IsDir   bool      `json:"is_dir"`
+	Size    int64     `json:"size"` // zero for deleted paths and directories
+}
+
+// NewJSONSink returns a sink writing one JSON [Record] per line (JSON lines),
+// with paths relative to base in RelPath.
+func NewJSONSink(w io.Writer, base string) Sink {
+	return &jsonSink{w: w, base: base}
+}
+
+type jsonSink struct {
+	w    io.Writer
+	base string
+}
+
+func (s *jsonSink) Write(changes []Change) error {
+	now := time.Now()
+
+	// write each batch at once, so that readers never see partial lines
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, c := range changes {
+		r := Record{
+			Time:    now,
+			Op:      c.Kind.String(),
+			Path:    absolute(c.Path),
+			RelPath: relativeTo(s.base, c.Path),
+		}
+		if c.From != "" {
+			r.From = absolute(c.From)
+		}
+		if info, err := os.Stat(c.Path); err == nil && c.Kind != Deleted {
+			r.IsDir = info.IsDir()
+			if !r.IsDir {
+				r.Size = info.Size()
+			}
+		}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// NewNullSink returns a sink writing the changed paths relative to base,
+// each followed by a null byte like find -print0, to be read by xargs -0.
+// The new path of renamed changes is written.
+func NewNullSink(w io.Writer, base string) Sink {
+	return &nullSink{w: w, base: base}
+}
+
+type nullSink struct {
+	w    io.Writer
+	base string
+}
+
+func (s *nullSink) Write(changes []Change) error {
+	var buf bytes.Buffer
+	for _, c := range changes {
+		buf.WriteString(relativeTo(s.base, c.Path))
+		buf.WriteByte(0)
+	}
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// absolute returns the absolute path of path, or path if it is not possible
+func absolute(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// relativeTo returns path relative to base, or path if it is not possible
+func relativeTo(base, path string) string {
+	if base == "" {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(base, abs)
+	if err != nil {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,70 @@
+package fswatch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONSink(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.txt": "hello", "sub/b.txt": "b"})
+
+	var buf bytes.Buffer
+	sink := NewJSONSink(&buf, root)
+	err := sink.Write([]Change{
+		{Path: filepath.Join(root, "a.txt"), Kind: Modified},
+		{Path: filepath.Join(root, "sub"), Kind: Created},
+		{Path: filepath.Join(root, "sub", "b.txt"), Kind: Renamed, From: filepath.Join(root, "b.txt")},
+		{Path: filepath.Join(root, "c.txt"), Kind: Deleted},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Record{
+		{Op: "modified", Path: filepath.Join(root, "a.txt"), RelPath: "a.txt", Size: 5},
+		{Op: "created", Path: filepath.Join(root, "sub"), RelPath: "sub", IsDir: true},
+		{Op: "renamed", Path: filepath.Join(root, "sub", "b.txt"), RelPath: filepath.Join("sub", "b.txt"), From: filepath.Join(root, "b.txt"), Size: 1},
+		{Op: "deleted", Path: filepath.Join(root, "c.txt"), RelPath: "c.txt"},
+	}
+	scanner := bufio.NewScanner(&buf)
+	var i int
+	for ; scanner.Scan(); i++ {
+		var got Record
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("line %d: unexpected error: %v", i, err)
+		}
+		if got.Time.IsZero() {
+			t.Errorf("line %d: expected a time", i)
+		}
+		got.Time = expected[i].Time
+		if got != expected[i] {
+			t.Errorf("line %d: expected %+v, got %+v", i, expected[i], got)
+		}
+	}
+	if i != len(expected) {
+		t.Errorf("expected %d lines, got %d", len(expected), i)
+	}
+}
+
+func TestNullSink(t *testing.T) {
+	root := t.TempDir()
+
+	var buf bytes.Buffer
+	sink := NewNullSink(&buf, root)
+	err := sink.Write([]Change{
+		{Path: filepath.Join(root, "a b.txt"), Kind: Modified},
+		{Path: filepath.Join(root, "sub", "c.txt"), Kind: Renamed, From: filepath.Join(root, "c.txt")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "a b.txt\x00" + filepath.Join("sub", "c.txt") + "\x00"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,280 @@
+package fswatch
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot is the state of a directory tree at a given time.
+//
+// Events are missed when nothing is watching (the watcher started late, the process restarted)
+// or when the OS drops them (see [fsnotify.ErrEventOverflow]): comparing two snapshots
+// with [Snapshot.Diff] gives the changes made meanwhile.
+type Snapshot struct {
+	Root    string           `json:"root"`
+	Time    time.Time        `json:"time"`
+	Entries map[string]Entry `json:"entries"` // by slash-separated path relative to Root
+}
+
+// Entry is the state of a path in a [Snapshot]
+type Entry struct {
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mtime"`
+	Mode    fs.FileMode `json:"mode"`
+	Hash    string      `json:"hash,omitempty"` // hash of the content of regular files, see [WithHash]
+}
+
+type SnapshotOpts struct {
+	hash   bool
+	ignore *Ignore
+}
+
+// WithHash hashes the content of regular files,
+// to detect modifications which keep the size and modification time, and renames.
+func WithHash() func(*SnapshotOpts) {
+	return func(opts *SnapshotOpts) {
+		opts.hash = true
+	}
+}
+
+// WithSnapshotIgnore skips the paths matched by ig
+func WithSnapshotIgnore(ig *Ignore) func(*SnapshotOpts) {
+	return func(opts *SnapshotOpts) {
+		opts.ignore = ig
+	}
+}
+
+// TakeSnapshot returns the current state of the tree under root.
+// Symbolic links are not followed.
+func TakeSnapshot(root string, opts ...func(*SnapshotOpts)) (*Snapshot, error) {
+	options := &SnapshotOpts{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{Root: root, Time: time.Now(), Entries: map[string]Entry{}}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != root && errors.Is(err, fs.ErrNotExist) {
+				// removed meanwhile
+				return nil
+			}
+			return err
+		}
+		if path != root && options.ignore.Match(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.Entries[s.rel(path)] = newEntry(path, info, options.hash)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newEntry(path string, info fs.FileInfo, hash bool) Entry {
+	e := Entry{Size: info.Size(), ModTime: info.ModTime(), Mode: info.Mode()}
+	if info.IsDir() {
+		// the size and modification time of a directory change with its entries
+		e.Size, e.ModTime = 0, time.Time{}
+	}
+	if hash && info.Mode().IsRegular() {
+		e.Hash, _ = hashFile(path)
+	}
+	return e
+}
+
+// rel returns the key of path in the entries
+func (s *Snapshot) rel(path string) string {
+	rel, err := filepath.Rel(s.Root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// LoadSnapshot reads a snapshot saved with [Snapshot.Save]
+func LoadSnapshot(path string) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if s.Entries == nil {
+		s.Entries = map[string]Entry{}
+	}
+	return s, nil
+}
+
+// Save writes the snapshot as JSON to path.
+// The file is replaced atomically, so that a crash never leaves a partial snapshot.
+func (s *Snapshot) Save(path string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Diff returns the changes from s to other, sorted by path.
+// Paths are absolute, under the root of other.
+//
+// A deleted file and a created file with the same content are one Renamed change,
+// if both snapshots were taken [WithHash].
+// A path whose type changed (e.g. a file replaced by a directory) is deleted then created.
+func (s *Snapshot) Diff(other *Snapshot) []Change {
+	var changes []Change
+	var deleted, created []string
+	for rel, o := range s.Entries {
+		n, ok := other.Entries[rel]
+		switch {
+		case !ok:
+			deleted = append(deleted, rel)
+		case o.Mode.Type() != n.Mode.Type():
+			changes = append(changes,
+				Change{Path: other.abs(rel), Kind: Deleted},
+				Change{Path: other.abs(rel), Kind: Created},
+			)
+		case o.modified(n):
+			changes = append(changes, Change{Path: other.abs(rel), Kind: Modified})
+		}
+	}
+	for rel := range other.Entries {
+		if _, ok := s.Entries[rel]; !ok {
+			created = append(created, rel)
+		}
+	}
+	sort.Strings(deleted)
+	sort.Strings(created)
+
+	// pair the deleted and created files by hash
+	byHash := map[string][]string{}
+	for _, rel := range deleted {
+		if h := s.Entries[rel].Hash; h != "" {
+			byHash[h] = append(byHash[h], rel)
+		}
+	}
+	renamed := map[string]bool{}
+	for _, rel := range created {
+		h := other.Entries[rel].Hash
+		if h == "" || len(byHash[h]) == 0 {
+			changes = append(changes, Change{Path: other.abs(rel), Kind: Created})
+			continue
+		}
+		from := byHash[h][0]
+		byHash[h] = byHash[h][1:]
+		renamed[from] = true
+		changes = append(changes, Change{Path: other.abs(rel), Kind: Renamed, From: other.abs(from)})
+	}
+	for _, rel := range deleted {
+		if !renamed[rel] {
+			changes = append(changes, Change{Path: other.abs(rel), Kind: Deleted})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// DiffTree returns the changes from s to the current state of its tree,
+// and a snapshot of the current state.
+// The options should be the ones s was taken with.
+func (s *Snapshot) DiffTree(opts ...func(*SnapshotOpts)) ([]Change, *Snapshot, error) {
+	current, err := TakeSnapshot(s.Root, opts...)
+	if errors.Is(err, fs.ErrNotExist) {
+		current = &Snapshot{Root: s.Root, Time: time.Now(), Entries: map[string]Entry{}}
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return s.Diff(current), current, nil
+}
+
+// modified returns true if the content or mode of e changed in n
+func (e Entry) modified(n Entry) bool {
+	if e.Hash != "" && n.Hash != "" && e.Hash != n.Hash {
+		return true
+	}
+	return e.Size != n.Size || !e.ModTime.Equal(n.ModTime) || e.Mode != n.Mode
+}
+
+// abs returns the absolute path of an entry
+func (s *Snapshot) abs(rel string) string {
+	if rel == "." {
+		return s.Root
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(rel))
+}
+
+// stat returns the current entry of path, and false if it does not exist
+func stat(path string, hash bool) (Entry, bool) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Entry{}, false
+	}
+	return newEntry(path, info, hash), true
+}
+
+// update sets the entry of path, see [stat],
+// or removes it with its descendants if it does not exist anymore.
+func (s *Snapshot) update(path string, e Entry, exists bool) {
+	rel := s.rel(path)
+	if exists {
+		s.Entries[rel] = e
+		return
+	}
+
+	old, ok := s.Entries[rel]
+	delete(s.Entries, rel)
+	if rel == "." {
+		clear(s.Entries)
+	} else if ok && old.Mode.IsDir() {
+		prefix := rel + "/"
+		for p := range s.Entries {
+			if strings.HasPrefix(p, prefix) {
+				delete(s.Entries, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package fswatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestSnapshotSave(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.txt": "a", "sub/b.txt": "b", ".git/HEAD": ""})
+
+	ig, err := NewIgnore(root, ".git/")
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	s, err := TakeSnapshot(root, WithHash(), WithSnapshotIgnore(ig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var keys []string
+	for k := range s.Entries {
+		keys = append(keys, k)
+	}
+	expected := []string{".", "a.txt", "sub", "sub/b.txt"}
+	if len(keys) != len(expected) {
+		t.Fatalf("expected entries %v, got %v", expected, keys)
+	}
+	for _, k := range expected {
+		if _, ok := s.Entries[k]; !ok {
+			t.Errorf("expected entry %s, got %v", k, keys)
+		}
+	}
+	if s.Entries["a.txt"].Hash == "" {
+		t.Errorf("expected a.txt to be hashed")
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := s.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changes := s.Diff(loaded); len(changes) != 0 {
+		t.Errorf("expected no changes after loading, got %v", changes)
+	}
+	if loaded.Root != s.Root {
+		t.Errorf("expected root %s, got %s", s.Root, loaded.Root)
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	type test struct {
+		name     string
+		change   func(t *testing.T, root string)
+		hash     bool
+		expected []string
+	}
+	tests := []test{
+		{
+			name: "created, modified and deleted",
+			change: func(t *testing.T, root string) {
+				writeFiles(t, root, map[string]string{"a.txt": "modified", "c.txt": "c"})
+				remove(t, filepath.Join(root, "sub"))
+			},
+			expected: []string{
+				"modified a.txt", "created c.txt", "deleted sub", "deleted sub/b.txt",
+			},
+		},
+		{
+			name: "renamed by hash",
+			change: func(t *testing.T, root string) {
+				rename(t, filepath.Join(root, "sub", "b.txt"), filepath.Join(root, "b.txt"))
+			},
+			hash:     true,
+			expected: []string{"renamed sub/b.txt -> b.txt"},
+		},
+		{
+			name: "renamed without hash",
+			change: func(t *testing.T, root string) {
+				rename(t, filepath.Join(root, "sub", "b.txt"), filepath.Join(root, "b.txt"))
+			},
+			expected: []string{"created b.txt", "deleted sub/b.txt"},
+		},
+		{
+			name: "same size and modification time",
+			change: func(t *testing.T, root string) {
+				path := filepath.Join(root, "a.txt")
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatalf("error in test setup: %v", err)
+				}
+				writeFiles(t, root, map[string]string{"a.txt": "b"})
+				if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+					t.Fatalf("error in test setup: %v", err)
+				}
+			},
+			hash:     true,
+			expected: []string{"modified a.txt"},
+		},
+		{
+			name: "file replaced by a directory",
+			change: func(t *testing.T, root string) {
+				remove(t, filepath.Join(root, "a.txt"))
+				writeFiles(t, root, map[string]string{"a.txt/c.txt": "c"})
+			},
+			expected: []string{"deleted a.txt", "created a.txt", "created a.txt/c.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+			var opts []func(*SnapshotOpts)
+			if test.hash {
+				opts = append(opts, WithHash())
+			}
+
+			s, err := TakeSnapshot(root, opts...)
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			test.change(t, root)
+			changes, _, err := s.DiffTree(opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := []string{}
+			for _, c := range changes {
+				c.Path, _ = filepath.Rel(s.Root, c.Path)
+				if c.From != "" {
+					c.From, _ = filepath.Rel(s.Root, c.From)
+				}
+				got = append(got, filepath.ToSlash(c.String()))
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestRescanOnOverflow(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+
+	b := newLimitedBackend(100)
+	w, err := NewWatcher(WithSource(b), WithRescan())
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan fsnotify.Event, 10)
+	errs := make(chan error, 10)
+	go func() {
+		errs <- w.WatchContext(ctx, func(e fsnotify.Event) {
+			events <- e
+		})
+	}()
+
+	// the events of these changes are lost
+	writeFiles(t, root, map[string]string{"a.txt": "modified", "new/c.txt": "c"})
+	remove(t, filepath.Join(root, "sub"))
+	b.errs <- fsnotify.ErrEventOverflow
+
+	expected := []string{
+		`WRITE         "a.txt"`,
+		`CREATE        "new"`,
+		`CREATE        "new/c.txt"`,
+		`REMOVE        "sub"`,
+		`REMOVE        "sub/b.txt"`,
+	}
+	var got []string
+	for len(got) < len(expected) {
+		select {
+		case e := <-events:
+			e.Name, _ = filepath.Rel(root, e.Name)
+			got = append(got, filepath.ToSlash(e.String()))
+		case err := <-errs:
+			t.Fatalf("unexpected error: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("expected events %v, got %v", expected, got)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected events %v, got %v", expected, got)
+	}
+
+	watched := w.Watched()
+	if !reflect.DeepEqual(watched, []string{root, filepath.Join(root, "new")}) {
+		t.Errorf("expected the new directory to be watched, got %v", watched)
+	}
+}
+
+func TestOverflowWithoutRescan(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.txt": "a"})
+
+	b := newLimitedBackend(100)
+	w, err := NewWatcher(WithSource(b))
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	if len(w.snapshots) != 0 {
+		t.Errorf("expected no snapshot, got %d", len(w.snapshots))
+	}
+
+	sub := w.Subscribe()
+	b.errs <- fsnotify.ErrEventOverflow
+	select {
+	case err := <-sub.Errors:
+		if !errors.Is(err, fsnotify.ErrEventOverflow) {
+			t.Errorf("expected %v, got %v", fsnotify.ErrEventOverflow, err)
+		}
+	case e := <-sub.Events:
+		t.Errorf("expected an error, got %v", e)
+	case <-time.After(time.Second):
+		t.Errorf("expected an error, got none")
+	}
+}
+
+func remove(t *testing.T, path string) {
+	t.Helper()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+}
+
+func rename(t *testing.T, from, to string) {
+	t.Helper()
+	if err := os.Rename(from, to); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+}
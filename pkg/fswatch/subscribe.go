@@ -0,0 +1,325 @@
+package fswatch
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Backpressure is what a [Subscription] does when its buffer is full
+type Backpressure int
+
+const (
+	// Block waits for the subscriber to receive, which delays the other subscriptions
+	Block Backpressure = iota
+	// DropOldest drops the oldest buffered event or error to make room for the new one
+	DropOldest
+)
+
+// DefaultBuffer is the buffer size of a [Subscription] when none is given
+const DefaultBuffer = 64
+
+// Subscription receives the events of a [Watcher] independently of the other subscriptions.
+//
+// Events and errors are sent on the Events and Errors channels,
+// which are closed once unsubscribed or once the watcher is closed.
+type Subscription struct {
+	Events <-chan Event
+	Errors <-chan error
+
+	w        *Watcher
+	events   chan Event
+	errs     chan error
+	prefix   string
+	filter   func(fsnotify.Event) bool
+	debounce time.Duration
+	policy   Backpressure
+	dropped  atomic.Uint64
+
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex // serializes the sends with closing
+	closed bool
+
+	tmu     sync.Mutex
+	timers  map[string]Timer
+	pending map[string]Event // debounced events by path
+}
+
+// Subscribe returns a new subscription to the events of the watcher.
+//
+// Every subscription receives all the events, after [Watcher.process],
+// unless restricted with [WithPrefix] and [WithFilter].
+// While subscriptions exist, the Events and Errors channels of the watcher
+// are consumed to feed them: they should not be read directly.
+// Without subscription, events stay pending like with [fsnotify.Watcher].
+//
+// The [WithErrorHandler] and [WithMaxWait] options are ignored.
+func (w *Watcher) Subscribe(opts ...func(*WatchOpts)) *Subscription {
+	options := &WatchOpts{}
+	for _, o := range opts {
+		o(options)
+	}
+	buffer := options.buffer
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	s := &Subscription{
+		w:        w,
+		events:   make(chan Event, buffer),
+		errs:     make(chan error, buffer),
+		filter:   options.filter,
+		debounce: options.debounce,
+		policy:   options.policy,
+		done:     make(chan struct{}),
+		timers:   map[string]Timer{},
+		pending:  map[string]Event{},
+	}
+	s.Events, s.Errors = s.events, s.errs
+	if options.prefix != "" {
+		s.prefix = absolute(options.prefix)
+	}
+
+	w.subsMu.Lock()
+	if w.dispatched {
+		// the watcher is closed
+		w.subsMu.Unlock()
+		s.close()
+		return s
+	}
+	w.subs[s] = true
+	w.subsCond.Broadcast()
+	w.subsMu.Unlock()
+
+	w.dispatchOnce.Do(func() {
+		go w.dispatch()
+	})
+	return s
+}
+
+// dispatch sends the events and errors of the watcher to the subscriptions,
+// until the watcher is closed.
+func (w *Watcher) dispatch() {
+	defer func() {
+		w.stopHeld()
+		w.subsMu.Lock()
+		w.dispatched = true
+		subs := w.subs
+		w.subs = nil
+		w.subsMu.Unlock()
+		for s := range subs {
+			s.close()
+		}
+	}()
+
+	for {
+		// leave the events pending while there is no subscription
+		w.subsMu.Lock()
+		for len(w.subs) == 0 && !w.closed() {
+			w.subsCond.Wait()
+		}
+		w.subsMu.Unlock()
+
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			events, err := w.process(event)
+			subs := w.subscriptions()
+			for _, e := range events {
+				for _, e := range w.hold(e) {
+					for _, s := range subs {
+						s.send(e)
+					}
+				}
+			}
+			if err != nil {
+				for _, s := range subs {
+					s.sendError(err)
+				}
+			}
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			for _, s := range w.subscriptions() {
+				s.sendError(err)
+			}
+		}
+	}
+}
+
+// closed returns true once the watcher is closed
+func (w *Watcher) closed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *Watcher) subscriptions() []*Subscription {
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+
+	subs := make([]*Subscription, 0, len(w.subs))
+	for s := range w.subs {
+		subs = append(subs, s)
+	}
+	return subs
+}
+
+// Unsubscribe stops the subscription and closes its channels.
+// Debounced events not yet sent are dropped.
+func (s *Subscription) Unsubscribe() {
+	s.w.subsMu.Lock()
+	delete(s.w.subs, s)
+	s.w.subsMu.Unlock()
+	s.close()
+}
+
+// Dropped returns the number of events and errors dropped because the buffer was full
+// (see [DropOldest]).
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() {
+		// unblock a pending send before taking the lock
+		close(s.done)
+
+		s.tmu.Lock()
+		for name, t := range s.timers {
+			t.Stop()
+			delete(s.timers, name)
+		}
+		s.tmu.Unlock()
+
+		s.mu.Lock()
+		s.closed = true
+		close(s.events)
+		close(s.errs)
+		s.mu.Unlock()
+	})
+}
+
+// send sends an event matching the subscription, after the debounce period if any
+func (s *Subscription) send(e Event) {
+	if s.prefix != "" && !isUnder(absolute(e.Name), s.prefix) {
+		return
+	}
+	if s.filter != nil && !s.filter(e.Event) {
+		return
+	}
+	if s.debounce <= 0 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.closed {
+			offer(s.events, e, s.policy, s.done, &s.dropped)
+		}
+		return
+	}
+
+	s.tmu.Lock()
+	defer s.tmu.Unlock()
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	// the operations on a path during the debounce period are merged into one event
+	if p, ok := s.pending[e.Name]; ok {
+		e.Op |= p.Op
+		if p.OldHash != "" || p.NewHash != "" {
+			e.OldHash = p.OldHash
+		}
+	}
+	s.pending[e.Name] = e
+	if t, ok := s.timers[e.Name]; ok {
+		t.Reset(s.debounce)
+		return
+	}
+	name := e.Name
+	s.timers[name] = s.w.clock.AfterFunc(s.debounce, func() {
+		s.tmu.Lock()
+		e, ok := s.pending[name]
+		delete(s.pending, name)
+		delete(s.timers, name)
+		s.tmu.Unlock()
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.closed {
+			offer(s.events, e, s.policy, s.done, &s.dropped)
+		}
+	})
+}
+
+func (s *Subscription) sendError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		offer(s.errs, err, s.policy, s.done, &s.dropped)
+	}
+}
+
+// offer sends v on ch according to the policy, until done is closed.
+// It must not be called concurrently for the same channel.
+func offer[T any](ch chan T, v T, policy Backpressure, done chan struct{}, dropped *atomic.Uint64) {
+	if policy == DropOldest {
+		for {
+			select {
+			case ch <- v:
+				return
+			default:
+			}
+			select {
+			case <-ch:
+				dropped.Add(1)
+			default:
+			}
+		}
+	}
+
+	select {
+	case ch <- v:
+	case <-done:
+	}
+}
+
+// WithPrefix restricts the events to the paths under prefix.
+// It applies to [Watcher.Subscribe] and the Watch methods.
+func WithPrefix(prefix string) func(*WatchOpts) {
+	return func(opts *WatchOpts) {
+		opts.prefix = filepath.Clean(prefix)
+	}
+}
+
+// WithDebounce makes a [Subscription] send the events of a path once no event happened
+// on it for the duration d, with their operations merged into one event.
+func WithDebounce(d time.Duration) func(*WatchOpts) {
+	return func(opts *WatchOpts) {
+		opts.debounce = d
+	}
+}
+
+// WithBuffer sets the buffer size of a [Subscription],
+// and what to do when it is full. By default, it is [DefaultBuffer] with [Block].
+func WithBuffer(size int, policy Backpressure) func(*WatchOpts) {
+	return func(opts *WatchOpts) {
+		opts.buffer = size
+		opts.policy = policy
+	}
+}
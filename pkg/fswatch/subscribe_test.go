@@ -0,0 +1,175 @@
+package fswatch
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// receive returns the next event of a subscription
+func receive(t *testing.T, s *Subscription) Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-s.Events:
+		if !ok {
+			t.Fatal("unexpected closed subscription")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for an event")
+	}
+	return Event{}
+}
+
+func TestSubscribe(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	dir := t.TempDir()
+	all := w.Subscribe()
+	sub := w.Subscribe(WithPrefix(filepath.Join(dir, "sub")))
+	written := w.Subscribe(WithFilter(func(e fsnotify.Event) bool {
+		return e.Has(fsnotify.Write)
+	}))
+
+	events := []fsnotify.Event{
+		{Name: filepath.Join(dir, "a"), Op: fsnotify.Write},
+		{Name: filepath.Join(dir, "sub", "b"), Op: fsnotify.Chmod},
+		{Name: filepath.Join(dir, "subdir", "c"), Op: fsnotify.Write},
+	}
+	for _, e := range events {
+		w.Events <- e
+	}
+
+	type test struct {
+		name     string
+		sub      *Subscription
+		expected []fsnotify.Event
+	}
+	tests := []test{
+		{"all", all, events},
+		{"prefix", sub, events[1:2]},
+		{"filter", written, []fsnotify.Event{events[0], events[2]}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, expected := range test.expected {
+				if got := receive(t, test.sub); got.Event != expected {
+					t.Errorf("expected %v, got %v", expected, got)
+				}
+			}
+		})
+	}
+
+	// closing the watcher closes the subscriptions
+	w.Close()
+	for _, s := range []*Subscription{all, sub, written} {
+		select {
+		case e, ok := <-s.Events:
+			if ok {
+				t.Errorf("unexpected event %v", e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for the subscription to close")
+		}
+	}
+	if _, ok := <-w.Subscribe().Events; ok {
+		t.Errorf("expected a subscription to a closed watcher to be closed")
+	}
+}
+
+func TestSubscribeDropOldest(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	sub := w.Subscribe(WithBuffer(2, DropOldest))
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		w.Events <- fsnotify.Event{Name: name, Op: fsnotify.Write}
+	}
+	waitFor(t, "dropped events", func() bool {
+		return sub.Dropped() == 3
+	})
+
+	for _, expected := range []string{"d", "e"} {
+		if got := receive(t, sub); got.Name != expected {
+			t.Errorf("expected %s, got %s", expected, got.Name)
+		}
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	// a blocked subscription delays the others until it is unsubscribed
+	blocked := w.Subscribe(WithBuffer(1, Block))
+	other := w.Subscribe()
+	go func() {
+		for _, name := range []string{"a", "b", "c"} {
+			w.Events <- fsnotify.Event{Name: name, Op: fsnotify.Write}
+		}
+	}()
+	if e := receive(t, blocked); e.Name != "a" {
+		t.Errorf("expected a, got %v", e)
+	}
+	// b fills the buffer, c blocks
+	waitFor(t, "a full buffer", func() bool {
+		return len(blocked.Events) == 1
+	})
+	blocked.Unsubscribe()
+
+	for _, expected := range []string{"a", "b", "c"} {
+		if got := receive(t, other); got.Name != expected {
+			t.Errorf("expected %s, got %s", expected, got.Name)
+		}
+	}
+	if e := <-blocked.Events; e.Name != "b" {
+		t.Errorf("expected the buffered event b, got %v", e)
+	}
+	if _, ok := <-blocked.Events; ok {
+		t.Errorf("expected the subscription to be closed")
+	}
+}
+
+func TestSubscribeDebounce(t *testing.T) {
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	sub := w.Subscribe(WithDebounce(50 * time.Millisecond))
+	w.Events <- fsnotify.Event{Name: "a", Op: fsnotify.Write}
+	w.Events <- fsnotify.Event{Name: "b", Op: fsnotify.Write}
+	w.Events <- fsnotify.Event{Name: "a", Op: fsnotify.Chmod}
+
+	got := map[string]fsnotify.Op{}
+	for range 2 {
+		e := receive(t, sub)
+		got[e.Name] = e.Op
+	}
+	if got["a"] != fsnotify.Write|fsnotify.Chmod {
+		t.Errorf("expected a with ops %v, got %v", fsnotify.Write|fsnotify.Chmod, got["a"])
+	}
+	if got["b"] != fsnotify.Write {
+		t.Errorf("expected b with ops %v, got %v", fsnotify.Write, got["b"])
+	}
+
+	select {
+	case e := <-sub.Events:
+		t.Errorf("unexpected event %v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
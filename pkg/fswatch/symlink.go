@@ -0,0 +1,286 @@
+package fswatch
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// SymlinkPolicy is how [Watcher.AddRecursive] handles symbolic links
+type SymlinkPolicy int
+
+const (
+	// SymlinkLink reports a link as an entry of its directory (created, removed, replaced),
+	// without watching its target. This is the default.
+	SymlinkLink SymlinkPolicy = iota
+	// SymlinkIgnore drops the events of links, except when they are removed
+	// since a removed path cannot be told to be a link.
+	SymlinkIgnore
+	// SymlinkFollow watches the directories targeted by links like subdirectories,
+	// including links created later. A directory already watched is not watched again,
+	// and a link to one of its ancestors is not followed, so that cycles end.
+	// Events are reported under both the real path and the paths through links.
+	SymlinkFollow
+)
+
+// WithSymlinks sets the symlink policy of the watcher, see [SymlinkPolicy]
+func WithSymlinks(policy SymlinkPolicy) func(*Watcher) {
+	return func(w *Watcher) {
+		w.symlinks = policy
+	}
+}
+
+// treeAdder watches the directories of a tree, see [Watcher.AddRecursive]
+type treeAdder struct {
+	w       *Watcher
+	visited map[string]bool // absolute paths of the directories watched, to end cycles
+	limit   *WatchLimitError
+	failed  string                 // the first path which could not be watched
+	files   map[string]fs.FileInfo // regular files, to hash with WithHashOnAdd
+}
+
+func (w *Watcher) newTreeAdder() *treeAdder {
+	a := &treeAdder{w: w, visited: map[string]bool{}, files: map[string]fs.FileInfo{}}
+	for _, path := range w.Watched() {
+		if abs, err := filepath.Abs(path); err == nil {
+			a.visited[abs] = true
+		}
+	}
+	return a
+}
+
+// walk watches root and its subdirectories.
+// If the OS cannot watch more paths, it keeps walking to count the remaining directories.
+func (a *treeAdder) walk(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil && a.limit == nil {
+			return &AddError{Path: path, Err: err}
+		}
+		if err != nil {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 && a.w.symlinks == SymlinkFollow {
+			return a.follow(path)
+		}
+		if d.Type().IsRegular() && a.w.seeds() && !a.w.ignore.Match(path, false) {
+			if info, err := d.Info(); err == nil {
+				a.files[filepath.Clean(path)] = info
+			}
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && a.w.ignore.Match(path, true) {
+			return filepath.SkipDir
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			if path != root && a.visited[abs] {
+				// reached through a link already
+				return filepath.SkipDir
+			}
+			a.visited[abs] = true
+		}
+
+		if a.limit != nil {
+			// count the remaining directories
+			a.limit.Remaining++
+			return nil
+		}
+		err = a.w.Add(path)
+		if errors.As(err, &a.limit) {
+			a.limit.Remaining = 1
+			a.failed = path
+			return nil
+		}
+		if err != nil {
+			return &AddError{Path: path, Err: err}
+		}
+		log.Println("watching path:", path)
+		return nil
+	})
+}
+
+// follow records a link to report the events of its target under it,
+// and watches the target unless it is watched already.
+// A link to one of its ancestors is a cycle, it is not followed.
+func (a *treeAdder) follow(link string) error {
+	real := realDir(link)
+	if real == "" || a.w.ignore.Match(link, true) || isAncestor(real, link) {
+		return nil
+	}
+	a.w.mu.Lock()
+	if !slices.Contains(a.w.links[real], filepath.Clean(link)) {
+		a.w.links[real] = append(a.w.links[real], filepath.Clean(link))
+	}
+	a.w.mu.Unlock()
+
+	if a.visited[real] {
+		return nil
+	}
+	return a.walk(real)
+}
+
+// err returns the error for the directories which could not be watched, if any
+func (a *treeAdder) err() error {
+	if a.limit != nil {
+		return &AddError{Path: a.failed, Err: a.limit}
+	}
+	return nil
+}
+
+// realDir returns the absolute real path of the directory targeted by a link,
+// or an empty string if it is not a link to a directory
+func realDir(link string) string {
+	info, err := os.Lstat(link)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return ""
+	}
+	real, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		// dangling
+		return ""
+	}
+	if info, err := os.Stat(real); err != nil || !info.IsDir() {
+		return ""
+	}
+	real, err = filepath.Abs(real)
+	if err != nil {
+		return ""
+	}
+	return real
+}
+
+// isAncestor returns true if the directory dir contains link, once the links are resolved
+func isAncestor(dir, link string) bool {
+	parent, err := filepath.EvalSymlinks(filepath.Dir(link))
+	if err != nil {
+		return false
+	}
+	parent, err = filepath.Abs(parent)
+	return err == nil && isUnder(parent, dir)
+}
+
+// isLink returns true if path is a symbolic link
+func isLink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&fs.ModeSymlink != 0
+}
+
+// aliases returns the other paths of path through the followed links
+func (w *Watcher) aliases(path string) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.links) == 0 {
+		return nil
+	}
+
+	// links can be reached through other links, each link is used once per path
+	// so that links targeting each other end
+	type alias struct {
+		path string
+		used []string // links
+	}
+	seen := map[string]bool{abs: true}
+	queue := []alias{{path: abs}}
+	var paths []string
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for real, links := range w.links {
+			if !isUnder(p.path, real) {
+				continue
+			}
+			rel, _ := filepath.Rel(real, p.path)
+			for _, link := range links {
+				if slices.Contains(p.used, link) {
+					continue
+				}
+				path := filepath.Join(link, rel)
+				if abs, err := filepath.Abs(path); err == nil && !seen[abs] {
+					seen[abs] = true
+					queue = append(queue, alias{path: abs, used: append(slices.Clone(p.used), link)})
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// withAliases returns the events with the same events for the aliases of their paths
+func (w *Watcher) withAliases(events []fsnotify.Event) []fsnotify.Event {
+	var all []fsnotify.Event
+	for _, e := range events {
+		all = append(all, e)
+		for _, alias := range w.aliases(e.Name) {
+			all = append(all, fsnotify.Event{Name: alias, Op: e.Op})
+		}
+	}
+	return all
+}
+
+// isLinked returns true if path is under a directory watched through a link
+func (w *Watcher) isLinked(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for real := range w.links {
+		if isUnder(abs, real) {
+			return true
+		}
+	}
+	return false
+}
+
+// unlink forgets a followed link which was removed,
+// and stops watching its target if it is not reached otherwise.
+func (w *Watcher) unlink(link string) {
+	link = filepath.Clean(link)
+
+	w.mu.Lock()
+	var unwatch []string
+	for real, links := range w.links {
+		kept := links[:0]
+		for _, l := range links {
+			if !isUnder(l, link) {
+				kept = append(kept, l)
+			}
+		}
+		if len(kept) > 0 {
+			w.links[real] = kept
+			continue
+		}
+		delete(w.links, real)
+
+		rooted := false
+		for _, root := range w.roots {
+			if abs, err := filepath.Abs(root); err == nil && isUnder(real, abs) {
+				rooted = true
+			}
+		}
+		if !rooted {
+			unwatch = append(unwatch, real)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, real := range unwatch {
+		w.unwatch(real)
+	}
+}
@@ -0,0 +1,224 @@
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+}
+
+func TestSymlinkFollow(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	other, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	writeFiles(t, root, map[string]string{"src/main.go": ""})
+	writeFiles(t, other, map[string]string{"lib/sub/a.go": ""})
+	symlink(t, filepath.Join(other, "lib"), filepath.Join(root, "lib"))
+	// cycles
+	symlink(t, root, filepath.Join(root, "src", "loop"))
+	symlink(t, filepath.Join(root, "lib"), filepath.Join(other, "lib", "sub", "back"))
+
+	w, err := NewWatcher(WithSymlinks(SymlinkFollow))
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		root,
+		filepath.Join(other, "lib"),
+		filepath.Join(other, "lib", "sub"),
+		filepath.Join(root, "src"),
+	}
+	slices.Sort(expected)
+	if got := w.Watched(); !slices.Equal(got, expected) {
+		t.Errorf("expected watched %v, got %v", expected, got)
+	}
+
+	// events are reported under both paths
+	sub := w.Subscribe()
+	real := filepath.Join(other, "lib", "sub", "a.go")
+	writeFiles(t, other, map[string]string{"lib/sub/a.go": "modified"})
+	got := []string{receive(t, sub).Name, receive(t, sub).Name}
+	expected = []string{real, filepath.Join(root, "lib", "sub", "a.go")}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected events for %v, got %v", expected, got)
+	}
+
+	// removing the link stops watching the target
+	if err := os.Remove(filepath.Join(root, "lib")); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	waitFor(t, "the target to be unwatched", func() bool {
+		return !slices.Contains(w.Watched(), filepath.Join(other, "lib"))
+	})
+}
+
+// countingBackend counts the watches added for every path
+type countingBackend struct {
+	*limitedBackend
+	adds map[string]int
+}
+
+func (b *countingBackend) Add(name string) error {
+	b.adds[name]++
+	return b.limitedBackend.Add(name)
+}
+
+// TestSymlinkAliases checks that a link is reported whether its target is walked before or after it
+func TestSymlinkAliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		dirs     []string
+		links    map[string]string // link -> target, relative to the root
+		path     string
+		expected []string
+	}{
+		{name: "link walked first", dirs: []string{"z"}, links: map[string]string{"a": "z"}, path: "z/f", expected: []string{"a/f"}},
+		{name: "target walked first", dirs: []string{"a"}, links: map[string]string{"z": "a"}, path: "a/f", expected: []string{"z/f"}},
+		{
+			name:     "links to each other",
+			dirs:     []string{"a", "b"},
+			links:    map[string]string{"a/to-b": "b", "b/to-a": "a"},
+			path:     "a/f",
+			expected: []string{"a/to-b/to-a/f", "b/to-a/f"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, err := filepath.EvalSymlinks(t.TempDir())
+			if err != nil {
+				t.Fatalf("error in test setup: %v", err)
+			}
+			for _, dir := range test.dirs {
+				writeFiles(t, root, map[string]string{dir + "/f": ""})
+			}
+			for link, target := range test.links {
+				symlink(t, filepath.Join(root, target), filepath.Join(root, link))
+			}
+
+			b := &countingBackend{limitedBackend: newLimitedBackend(100), adds: map[string]int{}}
+			w, err := NewWatcher(WithSource(b), WithSymlinks(SymlinkFollow))
+			if err != nil {
+				t.Fatalf("error in test setup: creating watcher: %v", err)
+			}
+			defer w.Close()
+			if err := w.AddRecursive(root); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var expected []string
+			for _, alias := range test.expected {
+				expected = append(expected, filepath.Join(root, alias))
+			}
+			if got := w.aliases(filepath.Join(root, test.path)); !slices.Equal(got, expected) {
+				t.Errorf("expected aliases %v, got %v", expected, got)
+			}
+			for path, n := range b.adds {
+				if n != 1 {
+					t.Errorf("expected %s to be watched once, got %d times", path, n)
+				}
+			}
+		})
+	}
+}
+
+func TestSymlinkFollowCreated(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	other, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	writeFiles(t, other, map[string]string{"a.go": ""})
+
+	w, err := NewWatcher(WithSymlinks(SymlinkFollow))
+	if err != nil {
+		t.Fatalf("error in test setup: creating watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.AddRecursive(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sub := w.Subscribe()
+
+	link := filepath.Join(root, "link")
+	symlink(t, other, link)
+
+	expected := []fsnotify.Event{
+		{Name: link, Op: fsnotify.Create},
+		{Name: filepath.Join(other, "a.go"), Op: fsnotify.Create},
+		{Name: filepath.Join(link, "a.go"), Op: fsnotify.Create},
+	}
+	for _, e := range expected {
+		if got := receive(t, sub); got.Event != e {
+			t.Errorf("expected %v, got %v", e, got)
+		}
+	}
+	if !slices.Contains(w.Watched(), other) {
+		t.Errorf("expected %s to be watched, got %v", other, w.Watched())
+	}
+}
+
+func TestSymlinkPolicy(t *testing.T) {
+	type test struct {
+		policy   SymlinkPolicy
+		expected []string
+	}
+	tests := []test{
+		{SymlinkLink, []string{"link", "file"}},
+		{SymlinkIgnore, []string{"file"}},
+	}
+
+	for _, test := range tests {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{"dir/a": ""})
+
+		w, err := NewWatcher(WithSymlinks(test.policy))
+		if err != nil {
+			t.Fatalf("error in test setup: creating watcher: %v", err)
+		}
+		defer w.Close()
+		if err := w.AddRecursive(root); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sub := w.Subscribe()
+
+		symlink(t, filepath.Join(root, "dir"), filepath.Join(root, "link"))
+		writeFiles(t, root, map[string]string{"file": ""})
+
+		var got []string
+		for !slices.Contains(got, "file") {
+			e := receive(t, sub)
+			if name := filepath.Base(e.Name); !slices.Contains(got, name) {
+				got = append(got, name)
+			}
+		}
+		if !slices.Equal(got, test.expected) {
+			t.Errorf("policy %d: expected events for %v, got %v", test.policy, test.expected, got)
+		}
+		if slices.Contains(w.Watched(), filepath.Join(root, "link")) {
+			t.Errorf("policy %d: expected the link not to be followed", test.policy)
+		}
+	}
+}
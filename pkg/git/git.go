@@ -7,12 +7,23 @@ import (
 	"github.com/gforien/go/pkg/semver"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 // GetVersion returns the latest semver tag in a repo
 // By default, prefix = ""
 // In a monorepo, you might want to set prefix = "my/module/"
 func GetVersion(repo *git.Repository, prefix string) (*plumbing.Reference, *semver.Version, error) {
+	return getVersion(repo, prefix, "")
+}
+
+// GetPreviousVersion is like [GetVersion] but ignores the given tag.
+// It is useful to compare a new tag against the previous release.
+func GetPreviousVersion(repo *git.Repository, prefix string, tag string) (*plumbing.Reference, *semver.Version, error) {
+	return getVersion(repo, prefix, tag)
+}
+
+func getVersion(repo *git.Repository, prefix string, exclude string) (*plumbing.Reference, *semver.Version, error) {
 	tags, err := repo.Tags()
 	if err != nil {
 		return nil, nil, fmt.Errorf("error fetching tags: %w", err)
@@ -25,6 +36,9 @@ func GetVersion(repo *git.Repository, prefix string) (*plumbing.Reference, *semv
 		if !strings.HasPrefix(tagPrefixed.Name().Short(), prefix) {
 			return nil
 		}
+		if exclude != "" && tagPrefixed.Name().Short() == exclude {
+			return nil
+		}
 		tag := strings.TrimPrefix(tagPrefixed.Name().Short(), prefix)
 		if t, err = semver.FromString(tag); err != nil {
 			return nil
@@ -74,6 +88,54 @@ func EnsureCommitSince(repo *git.Repository, ref *plumbing.Reference) error {
 	return nil
 }
 
+// CommitsSince returns the commits reachable from HEAD but not from the given ref,
+// most recent first. If ref is nil, all commits reachable from HEAD are returned.
+func CommitsSince(repo *git.Repository, ref *plumbing.Reference) ([]*object.Commit, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("error getting HEAD: %w", err)
+	}
+
+	stop := map[plumbing.Hash]bool{}
+	if ref != nil {
+		refCommit, err := peelCommit(repo, ref.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("error resolving ref commit: %w", err)
+		}
+		if err = object.NewCommitPreorderIter(refCommit, nil, nil).ForEach(func(c *object.Commit) error {
+			stop[c.Hash] = true
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("error iterating ref commits: %w", err)
+		}
+	}
+
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("error resolving head commit: %w", err)
+	}
+
+	var commits []*object.Commit
+	if err = object.NewCommitPreorderIter(headCommit, stop, nil).ForEach(func(c *object.Commit) error {
+		if !stop[c.Hash] {
+			commits = append(commits, c)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("error iterating commits: %w", err)
+	}
+
+	return commits, nil
+}
+
+// peelCommit resolves a hash to a commit, following annotated tags
+func peelCommit(repo *git.Repository, h plumbing.Hash) (*object.Commit, error) {
+	if tag, err := repo.TagObject(h); err == nil {
+		return tag.Commit()
+	}
+	return repo.CommitObject(h)
+}
+
 type ErrRefIsHead struct {
 	Ref *plumbing.Reference
 }
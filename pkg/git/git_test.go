@@ -2,12 +2,15 @@ package git
 
 import (
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gforien/go/pkg/semver"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/storage/memory"
 )
 
@@ -211,3 +214,82 @@ func TestEnsureCommitSince(t *testing.T) {
 		})
 	}
 }
+
+func TestCommitsSince(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatalf("error in test setup: creating in-memory repository: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("error in test setup: retrieving worktree: %v", err)
+	}
+
+	author := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	commit := func(msg string) plumbing.Hash {
+		h, err := wt.Commit(msg, &git.CommitOptions{AllowEmptyCommits: true, Author: author})
+		if err != nil {
+			t.Fatalf("error in test setup: creating commit: %v", err)
+		}
+		return h
+	}
+
+	commit("c1")
+	ref, err := repo.CreateTag("v1.0.0", commit("c2"), nil)
+	if err != nil {
+		t.Fatalf("error in test setup: creating tag: %v", err)
+	}
+	commit("c3")
+	commit("c4")
+
+	commits, err := CommitsSince(repo, ref)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var msgs []string
+	for _, c := range commits {
+		msgs = append(msgs, c.Message)
+	}
+	if strings.Join(msgs, ",") != "c4,c3" {
+		t.Errorf("expected [c4 c3], got %v", msgs)
+	}
+
+	all, err := CommitsSince(repo, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(all) != 4 {
+		t.Errorf("expected 4 commits, got %d", len(all))
+	}
+}
+
+func TestGetPreviousVersion(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatalf("error in test setup: creating in-memory repository: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("error in test setup: retrieving worktree: %v", err)
+	}
+
+	author := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "v2.0.0"} {
+		h, err := wt.Commit(tag, &git.CommitOptions{AllowEmptyCommits: true, Author: author})
+		if err != nil {
+			t.Fatalf("error in test setup: creating commit: %v", err)
+		}
+		if _, err = repo.CreateTag(tag, h, nil); err != nil {
+			t.Fatalf("error in test setup: creating tag %v: %v", tag, err)
+		}
+	}
+
+	_, v, err := GetPreviousVersion(repo, "", "v2.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := semver.Version{Major: 1, Minor: 1, Patch: 0}
+	if !expected.Equals(v) {
+		t.Errorf("expected %v, got %v", &expected, v)
+	}
+}
@@ -0,0 +1,252 @@
+// Package githooks installs git hooks that call back into a Go binary,
+// and implements the hooks themselves on top of [conventional], [git] and [semver].
+package githooks
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gforien/go/pkg/conventional"
+	"github.com/gforien/go/pkg/git"
+	"github.com/gforien/go/pkg/semver"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// Hooks are the hooks managed by this package
+var Hooks = []string{"commit-msg", "pre-push", "post-merge"}
+
+const (
+	// marker identifies the hooks written by [Install]
+	marker = "# managed by githooks"
+	// backupSuffix is appended to pre-existing hooks moved away by [Install]
+	backupSuffix = ".githooks-backup"
+)
+
+// Dir returns the hooks directory of the repository containing path.
+// It honors the core.hooksPath setting.
+func Dir(path string) (string, error) {
+	repo, err := gogit.PlainOpenWithOptions(path, &gogit.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error opening repository: %w", err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", fmt.Errorf("error reading config: %w", err)
+	}
+	if hooksPath := cfg.Raw.Section("core").Option("hooksPath"); hooksPath != "" {
+		if filepath.IsAbs(hooksPath) {
+			return hooksPath, nil
+		}
+		wt, err := repo.Worktree()
+		if err != nil {
+			return "", fmt.Errorf("error getting worktree: %w", err)
+		}
+		return filepath.Join(wt.Filesystem.Root(), hooksPath), nil
+	}
+
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return "", fmt.Errorf("repository at %s is not stored on disk", path)
+	}
+	return filepath.Join(storage.Filesystem().Root(), "hooks"), nil
+}
+
+// Install writes every hook of [Hooks] in dir as a small shim that runs
+//
+//	command... <hook> "$@"
+//
+// Installing is idempotent: hooks previously installed are overwritten.
+// Other existing hooks are renamed with a backup suffix and restored by [Uninstall].
+func Install(dir string, command []string) error {
+	if len(command) == 0 {
+		return errors.New("no command given")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating hooks directory: %w", err)
+	}
+
+	for _, hook := range Hooks {
+		path := filepath.Join(dir, hook)
+
+		managed, err := isManaged(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil && !managed {
+			backup := path + backupSuffix
+			if _, err := os.Stat(backup); err == nil {
+				return fmt.Errorf("cannot back up %s: %s already exists", path, backup)
+			}
+			if err := os.Rename(path, backup); err != nil {
+				return fmt.Errorf("error backing up hook: %w", err)
+			}
+		}
+
+		if err := os.WriteFile(path, []byte(shim(command, hook)), 0o755); err != nil {
+			return fmt.Errorf("error writing hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// Uninstall removes the hooks written by [Install] and restores the previous ones.
+// Hooks that were not installed by [Install] are left untouched.
+func Uninstall(dir string) error {
+	for _, hook := range Hooks {
+		path := filepath.Join(dir, hook)
+
+		managed, err := isManaged(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !managed {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("error removing hook: %w", err)
+		}
+		if err := os.Rename(path+backupSuffix, path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error restoring hook: %w", err)
+		}
+	}
+	return nil
+}
+
+func isManaged(path string) (bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(b), marker), nil
+}
+
+func shim(command []string, hook string) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString(marker + "\n")
+	b.WriteString("exec")
+	for _, arg := range append(slices.Clone(command), hook) {
+		b.WriteString(" " + quote(arg))
+	}
+	b.WriteString(` "$@"` + "\n")
+	return b.String()
+}
+
+// quote quotes s for a POSIX shell
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// CommitMsg validates the commit message stored in file.
+// See [conventional.Validate].
+func CommitMsg(file string, types ...string) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error reading commit message: %w", err)
+	}
+	return conventional.Validate(string(b), types...)
+}
+
+// zeroSHA is sent by git in place of the local object name when a ref is deleted
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// PrePush reads the refs being pushed, as written by git on the standard input of the pre-push hook
+//
+//	<local ref> SP <local object name> SP <remote ref> SP <remote object name> LF
+//
+// and returns ErrTagNotGreater if a semver tag is not greater than the previous release.
+func PrePush(repo *gogit.Repository, prefix string, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 {
+			continue
+		}
+		localRef, localSHA := fields[0], fields[1]
+
+		tag, ok := strings.CutPrefix(localRef, "refs/tags/")
+		if !ok || localSHA == zeroSHA || !strings.HasPrefix(tag, prefix) {
+			continue
+		}
+		v, err := semver.FromString(strings.TrimPrefix(tag, prefix))
+		if err != nil {
+			continue
+		}
+
+		_, previous, err := git.GetPreviousVersion(repo, prefix, tag)
+		if err != nil {
+			return err
+		}
+		if !v.GreaterThan(previous) {
+			return &ErrTagNotGreater{Tag: tag, Version: &v, Previous: previous}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading refs: %w", err)
+	}
+	return nil
+}
+
+// PostMerge writes the next version suggested by the commits since the latest release.
+func PostMerge(repo *gogit.Repository, prefix string, w io.Writer) error {
+	ref, v, err := git.GetVersion(repo, prefix)
+	if err != nil {
+		return err
+	}
+	commits, err := git.CommitsSince(repo, ref)
+	if err != nil {
+		return err
+	}
+
+	since := "the beginning"
+	tagPrefix := prefix + "v"
+	if ref != nil {
+		since = ref.Name().Short()
+		if !strings.HasPrefix(since, tagPrefix) {
+			tagPrefix = prefix
+		}
+	}
+
+	msgs := make([]string, 0, len(commits))
+	for _, c := range commits {
+		msgs = append(msgs, c.Message)
+	}
+	bump := conventional.NextBump(msgs)
+
+	switch {
+	case len(commits) == 0:
+		_, err = fmt.Fprintf(w, "no new commits since %s\n", since)
+	case bump == "":
+		_, err = fmt.Fprintf(w, "no release needed: %d commits since %s, none is a fix or a feature\n", len(commits), since)
+	default:
+		_, err = fmt.Fprintf(w, "suggested next version: %s%s (%s bump, %d commits since %s)\n",
+			tagPrefix, v.Bump(bump), bump, len(commits), since)
+	}
+	return err
+}
+
+type ErrTagNotGreater struct {
+	Tag      string
+	Version  *semver.Version
+	Previous *semver.Version
+}
+
+func (e *ErrTagNotGreater) Error() string {
+	return fmt.Sprintf("tag %s (%v) is not greater than the previous release %v", e.Tag, e.Version, e.Previous)
+}
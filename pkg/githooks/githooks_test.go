@@ -0,0 +1,191 @@
+package githooks
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestInstallUninstall(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "pre-push")
+	if err := os.WriteFile(existing, []byte("#!/bin/sh\necho custom\n"), 0o755); err != nil {
+		t.Fatalf("error in test setup: writing hook: %v", err)
+	}
+
+	command := []string{"/usr/local/bin/githooks", "run", "--prefix", "it's/"}
+
+	// installing twice must not back up our own hooks
+	for range 2 {
+		if err := Install(dir, command); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for _, hook := range Hooks {
+		b, err := os.ReadFile(filepath.Join(dir, hook))
+		if err != nil {
+			t.Fatalf("expected hook %s to be installed: %v", hook, err)
+		}
+		expected := `exec '/usr/local/bin/githooks' 'run' '--prefix' 'it'\''s/' '` + hook + `' "$@"`
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected hook %s to contain %q, got:\n%s", hook, expected, b)
+		}
+	}
+	b, err := os.ReadFile(existing + backupSuffix)
+	if err != nil || !strings.Contains(string(b), "custom") {
+		t.Errorf("expected existing hook to be backed up, got %q (%v)", b, err)
+	}
+
+	if err := Uninstall(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "pre-push" {
+		t.Errorf("expected only the original pre-push hook to remain, got %v", entries)
+	}
+	b, _ = os.ReadFile(existing)
+	if !strings.Contains(string(b), "custom") {
+		t.Errorf("expected existing hook to be restored, got %q", b)
+	}
+}
+
+func TestCommitMsg(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "COMMIT_EDITMSG")
+
+	if err := os.WriteFile(file, []byte("feat(githooks): add hooks\n# comment\n"), 0o644); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	if err := CommitMsg(file); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(file, []byte("add hooks\n"), 0o644); err != nil {
+		t.Fatalf("error in test setup: %v", err)
+	}
+	if err := CommitMsg(file); err == nil {
+		t.Errorf("expected an error, got nil")
+	}
+}
+
+// newRepo creates an in-memory repository with one commit per message.
+// A message of the form "msg@tag" also creates the given tag.
+func newRepo(t *testing.T, msgs ...string) *gogit.Repository {
+	t.Helper()
+
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatalf("error in test setup: creating in-memory repository: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("error in test setup: retrieving worktree: %v", err)
+	}
+
+	author := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	for _, msg := range msgs {
+		msg, tag, _ := strings.Cut(msg, "@")
+		h, err := wt.Commit(msg, &gogit.CommitOptions{AllowEmptyCommits: true, Author: author})
+		if err != nil {
+			t.Fatalf("error in test setup: creating commit: %v", err)
+		}
+		if tag != "" {
+			if _, err = repo.CreateTag(tag, h, nil); err != nil {
+				t.Fatalf("error in test setup: creating tag %v: %v", tag, err)
+			}
+		}
+	}
+	return repo
+}
+
+func TestPrePush(t *testing.T) {
+	repo := newRepo(t, "feat: a@v1.0.0", "feat: b@v1.2.0", "fix: c@v1.1.5")
+	sha := "1111111111111111111111111111111111111111"
+
+	cases := []struct {
+		name        string
+		stdin       string
+		expectError bool
+	}{
+		{
+			name:  "branch",
+			stdin: "refs/heads/main " + sha + " refs/heads/main " + zeroSHA + "\n",
+		},
+		{
+			name:  "greatest tag",
+			stdin: "refs/tags/v1.2.0 " + sha + " refs/tags/v1.2.0 " + zeroSHA + "\n",
+		},
+		{
+			name:        "older tag",
+			stdin:       "refs/tags/v1.1.5 " + sha + " refs/tags/v1.1.5 " + zeroSHA + "\n",
+			expectError: true,
+		},
+		{
+			name:  "deleted tag",
+			stdin: "(delete) " + zeroSHA + " refs/tags/v1.1.5 " + sha + "\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := PrePush(repo, "", strings.NewReader(tc.stdin))
+			if tc.expectError {
+				var notGreater *ErrTagNotGreater
+				if !errors.As(err, &notGreater) {
+					t.Errorf("expected ErrTagNotGreater, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPostMerge(t *testing.T) {
+	cases := []struct {
+		name     string
+		commits  []string
+		expected string
+	}{
+		{
+			name:     "no new commits",
+			commits:  []string{"feat: a@v1.0.0"},
+			expected: "no new commits since v1.0.0\n",
+		},
+		{
+			name:     "minor",
+			commits:  []string{"feat: a@v1.0.0", "fix: b", "feat: c", "docs: d"},
+			expected: "suggested next version: v1.1.0 (minor bump, 3 commits since v1.0.0)\n",
+		},
+		{
+			name:     "no release",
+			commits:  []string{"feat: a@1.0.0", "docs: b"},
+			expected: "no release needed: 1 commits since 1.0.0, none is a fix or a feature\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := PostMerge(newRepo(t, tc.commits...), "", &b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if b.String() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, b.String())
+			}
+		})
+	}
+}